@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Archive layout:
+//
+//	manifest.yml          archiveManifest
+//	.wpdev.yml
+//	.wpdev/templates/...  custom templates
+//	database.sql          fresh dump
+//	files/<path>...       content directories, relative to the project root
+const (
+	archiveManifestName = "manifest.yml"
+	archiveDumpName     = "database.sql"
+	archiveFilesPrefix  = "files/"
+)
+
+type archiveManifest struct {
+	WpdevVersion string    `yaml:"wpdev_version"`
+	Created      time.Time `yaml:"created"`
+	Name         string    `yaml:"name"`
+	Domain       string    `yaml:"domain"`
+	URL          string    `yaml:"url"`
+	PHP          string    `yaml:"php"`
+	Server       string    `yaml:"server"`
+	DBEngine     string    `yaml:"db_engine"`
+	DBVersion    string    `yaml:"db_version"`
+	Content      []string  `yaml:"content"`
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export <file.tar.zst>",
+	Short: "Bundle config, templates, a fresh DB dump and content into one archive",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		if _, err := os.Stat(".wpdev.yml"); err != nil {
+			return fmt.Errorf("no .wpdev.yml here; run export from a project root")
+		}
+		content, _ := cmd.Flags().GetStringSlice("content")
+		docroot := cfg.Web.Docroot
+		if docroot == "" {
+			docroot = "."
+		}
+		var dirs []string
+		for _, c := range content {
+			dir := filepath.ToSlash(filepath.Join(docroot, c))
+			if _, err := os.Stat(dir); err != nil {
+				fmt.Println("Skipping missing", dir)
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+
+		fmt.Println("Dumping database...")
+		dump, err := newScratchFile(filepath.Join(".wpdev", "db"), "export-*.sql.age", func(w io.Writer) error {
+			return dbDump(cfg, w, nil)
+		})
+		if err != nil { return err }
+		defer dump.Remove()
+
+		man := archiveManifest{
+			WpdevVersion: version,
+			Created:      time.Now(),
+			Name:         cfg.Name,
+			Domain:       cfg.Domain,
+			URL:          siteURL(cfg),
+			PHP:          cfg.Web.PHP,
+			Server:       cfg.Web.Server,
+			DBEngine:     cfg.Database.Engine,
+			DBVersion:    cfg.Database.Version,
+			Content:      dirs,
+		}
+		if err := writeExport(cfg, args[0], man, dump); err != nil {
+			_ = os.Remove(args[0])
+			return err
+		}
+		fmt.Println("Wrote", args[0])
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Recreate a project from a wpdev export in an empty directory",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := filepath.Abs(args[0])
+		if err != nil { return err }
+		if err := ensureEmptyDir(".", archive); err != nil { return err }
+
+		man, dump, err := extractExport(archive)
+		if err != nil { return err }
+		defer dump.Remove()
+		fmt.Printf("Imported %s (%s, PHP %s, %s:%s) exported by wpdev %s\n",
+			man.Name, man.Domain, man.PHP, man.DBEngine, man.DBVersion, man.WpdevVersion)
+
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		if domain, _ := cmd.Flags().GetString("domain"); domain != "" && domain != cfg.Domain {
+			cfg.Domain = domain
+			if err := saveConfig(".wpdev.yml", cfg); err != nil { return err }
+			fmt.Println("Domain changed to", domain)
+		}
+		if cfg.Database.Persist == "bind" && cfg.Database.DataPath != "" {
+			_ = os.MkdirAll(cfg.Database.DataPath, 0o755)
+		}
+		if cfg.TLS.Enabled && !certsPresent(cfg.Domain) {
+			if err := generateCertsForDomain(cfg.Domain); err != nil {
+				fmt.Printf("Warning: TLS certificate generation failed: %v\n", err)
+			}
+		}
+
+		// No WordPress install yet: the database comes from the archive
+		if err := startStack(cfg); err != nil { return err }
+		if err := waitForDB(cfg); err != nil { return err }
+
+		fmt.Println("Restoring database...")
+		r, err := dump.Reader()
+		if err != nil { return err }
+		if man.URL != "" && man.URL != siteURL(cfg) {
+			pr, pw := io.Pipe()
+			go func(f io.Reader) { pw.CloseWithError(newURLReplacer(man.URL, siteURL(cfg)).Copy(pw, f)) }(r)
+			r = pr
+		}
+		if err := dbImport(cfg, r); err != nil { return err }
+		if err := setupSite(cfg); err != nil { return err }
+		fmt.Println("Import complete:", siteURL(cfg))
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringSlice("content", []string{"wp-content/uploads", "wp-content/themes", "wp-content/plugins"},
+		"content directories to include, relative to the docroot")
+	importCmd.Flags().String("domain", "", "use a different domain (URLs in the database are rewritten)")
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+func writeExport(cfg *Config, dst string, man archiveManifest, dump *scratchFile) error {
+	mode := os.FileMode(0o644)
+	if encryptionEnabled(cfg) {
+		mode = 0o600
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil { return err }
+	defer out.Close()
+	enc, err := encryptTo(cfg, out)
+	if err != nil { return err }
+	zw, err := zstd.NewWriter(enc)
+	if err != nil { return err }
+	tw := tar.NewWriter(zw)
+
+	b, err := yaml.Marshal(man)
+	if err != nil { return err }
+	if err := tw.WriteHeader(&tar.Header{Name: archiveManifestName, Mode: 0o644, Size: int64(len(b)), ModTime: man.Created}); err != nil { return err }
+	if _, err := tw.Write(b); err != nil { return err }
+
+	if err := tarFile(tw, ".wpdev.yml", ".wpdev.yml"); err != nil { return err }
+	dr, err := dump.Reader()
+	if err != nil { return err }
+	if err := tw.WriteHeader(&tar.Header{Name: archiveDumpName, Mode: 0o600, Size: dump.size, ModTime: man.Created}); err != nil { return err }
+	if _, err := io.Copy(tw, dr); err != nil { return err }
+	if err := tarTree(tw, filepath.Join(".wpdev", "templates"), ""); err != nil { return err }
+	for _, dir := range man.Content {
+		if err := tarTree(tw, dir, archiveFilesPrefix); err != nil { return err }
+	}
+
+	if err := tw.Close(); err != nil { return err }
+	if err := zw.Close(); err != nil { return err }
+	if err := enc.Close(); err != nil { return err }
+	return out.Close()
+}
+
+func tarFile(tw *tar.Writer, src, name string) error {
+	st, err := os.Stat(src)
+	if err != nil { return err }
+	hdr, err := tar.FileInfoHeader(st, "")
+	if err != nil { return err }
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil { return err }
+	f, err := os.Open(src)
+	if err != nil { return err }
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// tarTree adds root recursively under prefix, keeping paths relative to the
+// project root.
+func tarTree(tw *tar.Writer, root, prefix string) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) { return nil }
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil { return err }
+		info, err := d.Info()
+		if err != nil { return err }
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil { return err }
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil { return err }
+		hdr.Name = prefix + filepath.ToSlash(p)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil { return err }
+		if !info.Mode().IsRegular() { return nil }
+		f, err := os.Open(p)
+		if err != nil { return err }
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// extractExport unpacks archive into the current directory and returns its
+// manifest. The dump stays encrypted in a scratch file the caller removes.
+func extractExport(archive string) (*archiveManifest, *scratchFile, error) {
+	in, err := os.Open(archive)
+	if err != nil { return nil, nil, err }
+	defer in.Close()
+	// No project config yet: decrypt with encryption.identity's defaults.
+	plain, err := decryptFrom(&Config{}, in)
+	if err != nil { return nil, nil, err }
+	zr, err := zstd.NewReader(plain)
+	if err != nil { return nil, nil, err }
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var man *archiveManifest
+	var dump *scratchFile
+	fail := func(err error) (*archiveManifest, *scratchFile, error) {
+		if dump != nil {
+			dump.Remove()
+		}
+		return nil, nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil { return fail(err) }
+
+		name := path.Clean(hdr.Name)
+		if name == archiveManifestName {
+			man = &archiveManifest{}
+			b, err := io.ReadAll(tr)
+			if err != nil { return fail(err) }
+			if err := yaml.Unmarshal(b, man); err != nil { return fail(err) }
+			continue
+		}
+		if name == archiveDumpName {
+			if dump != nil {
+				return fail(fmt.Errorf("more than one %s in archive", archiveDumpName))
+			}
+			dump, err = newScratchFile(".wpdev", "import-*.sql.age", func(w io.Writer) error {
+				_, err := io.Copy(w, tr)
+				return err
+			})
+			if err != nil { return fail(err) }
+			continue
+		}
+		name = strings.TrimPrefix(name, archiveFilesPrefix)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fail(fmt.Errorf("refusing unsafe path %q in archive", hdr.Name))
+		}
+		dst := filepath.FromSlash(name)
+		// An earlier symlink entry must not redirect later entries
+		if throughSymlink(dst) {
+			return fail(fmt.Errorf("refusing %q in archive: it would be written through a symlink", hdr.Name))
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0o755); err != nil { return fail(err) }
+		case tar.TypeSymlink:
+			target := path.Join(path.Dir(name), hdr.Linkname)
+			if path.IsAbs(hdr.Linkname) || target == ".." || strings.HasPrefix(target, "../") {
+				return fail(fmt.Errorf("refusing symlink %q -> %q in archive: it points outside the project", hdr.Name, hdr.Linkname))
+			}
+			_ = os.MkdirAll(filepath.Dir(dst), 0o755)
+			if err := os.Symlink(hdr.Linkname, dst); err != nil { return fail(err) }
+		case tar.TypeReg:
+			_ = os.MkdirAll(filepath.Dir(dst), 0o755)
+			f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm())
+			if err != nil { return fail(err) }
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil { return fail(err) }
+		}
+	}
+	if man == nil {
+		return fail(fmt.Errorf("%s is not a wpdev export (no %s)", archive, archiveManifestName))
+	}
+	if dump == nil {
+		return fail(fmt.Errorf("%s has no %s", archive, archiveDumpName))
+	}
+	return man, dump, nil
+}
+
+// throughSymlink reports whether rel or one of its parents already exists
+// as a symlink. Components that don't exist yet end the walk.
+func throughSymlink(rel string) bool {
+	p := ""
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return false
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureEmptyDir allows only dotfiles (e.g. .git, the .wpdev dir every
+// command creates) and the archive itself.
+func ensureEmptyDir(dir, archive string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil { return err }
+	for _, e := range entries {
+		abs, _ := filepath.Abs(filepath.Join(dir, e.Name()))
+		if abs == archive || (strings.HasPrefix(e.Name(), ".") && e.Name() != ".wpdev.yml") {
+			continue
+		}
+		return fmt.Errorf("import needs an empty directory (found %s)", e.Name())
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// profileFunc is one function's totals in a cachegrind profile.
+type profileFunc struct {
+	Name      string
+	File      string
+	Calls     int64
+	Inclusive time.Duration
+	Self      time.Duration
+}
+
+type profile struct {
+	Cmd   string
+	Total time.Duration
+	Funcs []*profileFunc
+}
+
+// readCachegrind parses an Xdebug cachegrind file, gzipped or not.
+func readCachegrind(path string) (*profile, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+		defer gz.Close()
+		r = gz
+	}
+	p, err := parseCachegrind(r)
+	if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return p, nil
+}
+
+// parseCachegrind aggregates the first event (time) per function. Cost lines
+// under fn= are self cost; a cost line after calls= is the inclusive cost of
+// that call and counts towards the caller's inclusive time. Names may be
+// compressed as "(id) name" on first use and "(id)" afterwards.
+func parseCachegrind(r io.Reader) (*profile, error) {
+	p := &profile{}
+	unit := 10 * time.Nanosecond // Xdebug 3: Time_(10ns)
+	names := map[string]map[string]string{"fl": {}, "fn": {}}
+	funcs := map[string]*profileFunc{}
+	fn := func(name, file string) *profileFunc {
+		f := funcs[name]
+		if f == nil {
+			f = &profileFunc{Name: name, File: file}
+			funcs[name] = f
+		}
+		return f
+	}
+	// file and function ids are shared between the fl/fi/fe/cfl and fn/cfn keys
+	table := map[string]string{"fl": "fl", "fi": "fl", "fe": "fl", "cfi": "fl", "cfl": "fl", "fn": "fn", "cfn": "fn"}
+	resolve := func(key, v string) string {
+		t := names[table[key]]
+		if !strings.HasPrefix(v, "(") {
+			return v
+		}
+		id, name, _ := strings.Cut(v, ")")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return t[id]
+		}
+		t[id] = name
+		return name
+	}
+
+	var (
+		file, callee string
+		calleeFile   string
+		cur          *profileFunc
+		pendingCalls int64
+		inCall       bool
+		sum, summary time.Duration
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		c := line[0]
+		if c >= '0' && c <= '9' || c == '+' || c == '-' || c == '*' {
+			fields := strings.Fields(line)
+			var cost time.Duration
+			if len(fields) > 1 {
+				n, err := strconv.ParseInt(fields[1], 10, 64)
+				if err != nil { return nil, fmt.Errorf("bad cost line %q", line) }
+				cost = time.Duration(n) * unit
+			}
+			if cur == nil {
+				continue
+			}
+			if inCall {
+				called := fn(callee, calleeFile)
+				called.Calls += pendingCalls
+				if callee != cur.Name { // recursion is already in the caller's total
+					cur.Inclusive += cost
+				}
+				inCall, calleeFile = false, ""
+				continue
+			}
+			cur.Self += cost
+			cur.Inclusive += cost
+			sum += cost
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if ok && !strings.Contains(key, " ") {
+			switch key {
+			case "fl":
+				file = resolve(key, val)
+			case "fi", "fe":
+				resolve(key, val)
+			case "cfi", "cfl":
+				calleeFile = resolve(key, val)
+			case "fn":
+				cur = fn(resolve(key, val), file)
+				if cur.File == "" {
+					cur.File = file
+				}
+			case "cfn":
+				callee = resolve(key, val)
+				if calleeFile == "" { // cfl= is left out when the callee is in the same file
+					calleeFile = file
+				}
+			case "calls":
+				n, _ := strconv.ParseInt(strings.Fields(val + " 0")[0], 10, 64)
+				pendingCalls, inCall = n, true
+			}
+			continue
+		}
+		key, val, ok = strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "cmd":
+			p.Cmd = val
+		case "events":
+			if ev := strings.Fields(val); len(ev) > 0 {
+				switch {
+				case strings.HasSuffix(ev[0], "(ns)"):
+					unit = time.Nanosecond
+				case strings.HasSuffix(ev[0], "(µs)"), strings.HasSuffix(ev[0], "(us)"):
+					unit = time.Microsecond
+				}
+			}
+		case "summary", "totals":
+			if n, err := strconv.ParseInt(strings.Fields(val + " 0")[0], 10, 64); err == nil {
+				summary = time.Duration(n) * unit
+			}
+		}
+	}
+	if err := sc.Err(); err != nil { return nil, err }
+	if len(funcs) == 0 {
+		return nil, fmt.Errorf("no functions found, not a cachegrind file?")
+	}
+
+	p.Total = summary
+	if p.Total == 0 {
+		p.Total = sum
+	}
+	for _, f := range funcs {
+		if f.Calls == 0 { // entry points like {main}
+			f.Calls = 1
+		}
+		p.Funcs = append(p.Funcs, f)
+	}
+	return p, nil
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// xdebugProfile is shaped like Xdebug 3 output: compressed names, cfl= left
+// out for callees in the same file, and a recursive call.
+const xdebugProfile = `version: 1
+creator: xdebug 3.3.2 (PHP 8.2.20)
+cmd: /var/www/html/index.php
+part: 1
+positions: line
+
+events: Time_(10ns) Memory_(bytes)
+
+fl=(1) php:internal
+fn=(1) php::strlen
+3 100 0
+
+fl=(2) /var/www/html/index.php
+fn=(2) helper
+5 200 64
+cfl=(1)
+cfn=(1)
+calls=2 0 0
+6 100 0
+
+fl=(2)
+fn=(3) fact
+9 10 0
+cfn=(3)
+calls=3 0 0
+9 30 0
+
+fl=(2)
+fn=(4) {main}
+
+summary: 400 1024
+
+1 50 0
+cfn=(2)
+calls=1 0 0
+2 300 0
+cfn=(3)
+calls=1 0 0
+3 40 0
+`
+
+func TestParseCachegrind(t *testing.T) {
+	us := time.Microsecond
+	tests := []struct {
+		name  string
+		in    string
+		total time.Duration
+		funcs map[string]profileFunc
+	}{
+		{
+			name:  "xdebug 3",
+			in:    xdebugProfile,
+			total: 4 * us,
+			funcs: map[string]profileFunc{
+				"php::strlen": {File: "php:internal", Calls: 2, Inclusive: 1 * us, Self: 1 * us},
+				"helper":      {File: "/var/www/html/index.php", Calls: 1, Inclusive: 3 * us, Self: 2 * us},
+				// recursive calls count, their time is already in the outer call
+				"fact":   {File: "/var/www/html/index.php", Calls: 4, Inclusive: 100 * time.Nanosecond, Self: 100 * time.Nanosecond},
+				"{main}": {File: "/var/www/html/index.php", Calls: 1, Inclusive: 3900 * time.Nanosecond, Self: 500 * time.Nanosecond},
+			},
+		},
+		{
+			name:  "uncompressed names, µs and no summary",
+			in:    "events: Time_(µs)\nfl=/a.php\nfn=a\n1 5\ncfl=/b.php\ncfn=b\ncalls=1 0\n2 7\nfl=/b.php\nfn=b\n1 7\n",
+			total: 12 * us,
+			funcs: map[string]profileFunc{
+				"a": {File: "/a.php", Calls: 1, Inclusive: 12 * us, Self: 5 * us},
+				"b": {File: "/b.php", Calls: 1, Inclusive: 7 * us, Self: 7 * us},
+			},
+		},
+		{
+			name:  "callee seen before its own block keeps its file",
+			in:    "events: Time_(ns)\nfl=(1) /a.php\nfn=(1) a\n1 5\ncfl=(2) /b.php\ncfn=(2) b\ncalls=1 0\n2 7\nfl=(2)\nfn=(2)\n1 7\n",
+			total: 12,
+			funcs: map[string]profileFunc{
+				"a": {File: "/a.php", Calls: 1, Inclusive: 12, Self: 5},
+				"b": {File: "/b.php", Calls: 1, Inclusive: 7, Self: 7},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseCachegrind(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Total != tt.total {
+				t.Errorf("total = %s, want %s", p.Total, tt.total)
+			}
+			if len(p.Funcs) != len(tt.funcs) {
+				t.Errorf("got %d functions, want %d", len(p.Funcs), len(tt.funcs))
+			}
+			for _, f := range p.Funcs {
+				want, ok := tt.funcs[f.Name]
+				if !ok {
+					t.Errorf("unexpected function %q", f.Name)
+					continue
+				}
+				want.Name = f.Name
+				if *f != want {
+					t.Errorf("%s = %+v, want %+v", f.Name, *f, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCachegrindErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{"empty", "", "no functions found"},
+		{"not cachegrind", "<?php echo 1;\n", "no functions found"},
+		{"bad cost", "fl=/a.php\nfn=a\n1 x\n", "bad cost line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCachegrind(strings.NewReader(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package cli
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"runtime"
+	"strings"
 )
 
 type Config struct {
@@ -11,18 +13,33 @@ type Config struct {
 	Web      WebCfg `yaml:"web"`
 	Database DBCfg  `yaml:"database"`
 	Services struct {
-		Redis   bool `yaml:"redis"`
-		Mailpit bool `yaml:"mailpit"`
-		Adminer bool `yaml:"adminer"`
+		Redis        bool `yaml:"redis"`
+		Mailpit      bool `yaml:"mailpit"`
+		Adminer      bool `yaml:"adminer"`
+		Cron         bool `yaml:"cron,omitempty"`          // sidecar running due WP-Cron events; disables WP_CRON
+		CronInterval int  `yaml:"cron_interval,omitempty"` // seconds between runs (default 60)
 	} `yaml:"services"`
 	Xdebug struct {
-		Enabled bool `yaml:"enabled"`
+		Enabled          bool   `yaml:"enabled,omitempty"`            // legacy on/off, same as mode debug,develop
+		Mode             string `yaml:"mode,omitempty"`               // off|debug|profile|trace|coverage|develop (wpdev xdebug)
+		StartWithRequest string `yaml:"start_with_request,omitempty"` // yes|trigger
+		ClientHost       string `yaml:"client_host,omitempty"`        // where the IDE listens (default host.docker.internal)
+		ClientPort       int    `yaml:"client_port,omitempty"`        // default 9003
 	} `yaml:"xdebug"`
 	Perf struct {
 		Sync     string   `yaml:"sync"`
 		Excludes []string `yaml:"excludes"`
 	} `yaml:"perf"`
 	TLS TLSCfg `yaml:"tls"`
+	MediaProxy      string `yaml:"media_proxy,omitempty"`       // origin serving uploads missing locally
+	MediaProxyCache bool   `yaml:"media_proxy_cache,omitempty"` // store proxied uploads on disk
+	WordPress    WordPressCfg      `yaml:"wordpress,omitempty"`
+	Environments map[string]EnvCfg `yaml:"environments,omitempty"`
+	Encryption   EncryptionCfg     `yaml:"encryption,omitempty"`
+	Mounts       []Mount           `yaml:"mounts,omitempty"`
+	PHP          PHPCfg            `yaml:"php,omitempty"`
+
+	phpImage string // set by renderTemplates, see PHPImage
 }
 
 type WebCfg struct {
@@ -37,12 +54,208 @@ type DBCfg struct {
 	Portforward string `yaml:"portforward"`
 	Persist     string `yaml:"persist"`
   	DataPath    string `yaml:"data_path"`
+	Sanitize    SanitizeCfg `yaml:"sanitize,omitempty"`
+	PerBranch   bool        `yaml:"per_branch,omitempty"`  // separate database per git branch
+	MainBranch  string      `yaml:"main_branch,omitempty"` // seed for new branches (default main)
+}
+
+// SanitizeCfg drives `wpdev db dump --sanitize`.
+type SanitizeCfg struct {
+	Presets  []string       `yaml:"presets,omitempty"`  // wordpress|woocommerce
+	Prefix   string         `yaml:"prefix,omitempty"`   // table prefix for presets (default wp_)
+	Password string         `yaml:"password,omitempty"` // login password set by the "password" action
+	Rules    []SanitizeRule `yaml:"rules,omitempty"`
+}
+
+type SanitizeRule struct {
+	Table      string            `yaml:"table"`
+	Column     string            `yaml:"column,omitempty"`
+	Action     string            `yaml:"action"` // email|hash|password|null|value|truncate|delete
+	Value      string            `yaml:"value,omitempty"`
+	Match      map[string]string `yaml:"match,omitempty"` // only rows whose columns equal these values
+	DateColumn string            `yaml:"date_column,omitempty"`
+	OlderThan  string            `yaml:"older_than,omitempty"` // e.g. 365d, 12w, 720h
+}
+
+// WordPressCfg drives the automatic core download and install on start.
+type WordPressCfg struct {
+	Version       string `yaml:"version,omitempty"` // e.g. 6.6.2 or latest
+	Locale        string `yaml:"locale,omitempty"`  // e.g. en_US, de_DE
+	Title         string `yaml:"title,omitempty"`
+	AdminUser     string `yaml:"admin_user,omitempty"`
+	AdminPassword string `yaml:"admin_password,omitempty"`
+	AdminEmail    string `yaml:"admin_email,omitempty"`
+	Multisite     string `yaml:"multisite,omitempty"` // subdomain|subdirectory
+	Domains       []string `yaml:"domains,omitempty"` // extra domains mapped to subsites (wpdev site add)
+	Debug         bool   `yaml:"debug,omitempty"`     // WP_DEBUG & co. in wp-config-wpdev.php (wpdev debug on|off)
+	Plugins       []PackageSpec `yaml:"plugins,omitempty"`
+	Themes        []PackageSpec `yaml:"themes,omitempty"`
+}
+
+// PackageSpec is a plugin or theme reconciled on start: a wordpress.org slug
+// (optionally slug@version), a local .zip, or a local directory that is
+// mounted into wp-content. Written as a plain string unless active is set.
+type PackageSpec struct {
+	Source  string `yaml:"source"`
+	Version string `yaml:"version,omitempty"` // pin for slugs; zips and dirs carry their own
+	Active  *bool  `yaml:"active,omitempty"`  // default: true for plugins, false for themes
+}
+
+func (p *PackageSpec) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		p.Source = n.Value
+		if p.kind() == "slug" {
+			p.Source, p.Version, _ = strings.Cut(p.Source, "@")
+		}
+		return nil
+	}
+	type plain PackageSpec
+	return n.Decode((*plain)(p))
+}
+
+func (p PackageSpec) MarshalYAML() (any, error) {
+	if p.Active == nil {
+		if p.Version == "" {
+			return p.Source, nil
+		}
+		if p.kind() == "slug" {
+			return p.Source + "@" + p.Version, nil
+		}
+	}
+	type plain PackageSpec
+	return plain(p), nil
+}
+
+// kind is slug, zip or dir.
+func (p PackageSpec) kind() string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(p.Source), ".zip"):
+		return "zip"
+	case strings.ContainsAny(p.Source, "/\\") || strings.HasPrefix(p.Source, "."):
+		return "dir"
+	}
+	return "slug"
+}
+
+// PHPCfg customizes the PHP image (web.php picks the version). Extensions,
+// PECL and apt packages need a rebuild; ini settings only a restart.
+type PHPCfg struct {
+	Extensions []string          `yaml:"extensions,omitempty"` // docker-php-ext-install names, e.g. pdo_pgsql
+	Pecl       []string          `yaml:"pecl,omitempty"`       // name or name@version, e.g. redis@6.0.2
+	Ini        map[string]string `yaml:"ini,omitempty"`        // rendered into zz-wpdev.ini
+	Packages   []string          `yaml:"packages,omitempty"`   // extra apt packages, e.g. libpq-dev
+}
+
+// PeclPackage is a php.pecl entry split for the Dockerfile template.
+type PeclPackage struct {
+	Name    string // extension name for docker-php-ext-enable
+	Install string // argument for pecl install, e.g. redis-6.0.2
+}
+
+func (p PHPCfg) PeclPackages() []PeclPackage {
+	var out []PeclPackage
+	for _, s := range p.Pecl {
+		name, version, ok := strings.Cut(s, "@")
+		install := name
+		if ok {
+			install += "-" + version
+		}
+		out = append(out, PeclPackage{Name: name, Install: install})
+	}
+	return out
+}
+
+// HasPecl reports whether name is listed, replacing a built-in pin.
+func (p PHPCfg) HasPecl(name string) bool {
+	for _, pk := range p.PeclPackages() {
+		if pk.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvCfg is a remote (staging/production) site reachable over SSH.
+type EnvCfg struct {
+	Host      string   `yaml:"host"`
+	User      string   `yaml:"user"`
+	Port      int      `yaml:"port,omitempty"`
+	Key       string   `yaml:"key,omitempty"`       // path to SSH private key
+	Path      string   `yaml:"path"`                // WordPress root on the server
+	URL       string   `yaml:"url"`                 // site URL, rewritten to/from Domain
+	Excludes  []string `yaml:"excludes,omitempty"`  // rsync excludes for uploads
+	Protected bool     `yaml:"protected,omitempty"` // refuse `wpdev push`
+}
+
+// EncryptionCfg encrypts dumps, snapshots and exports to age recipients.
+type EncryptionCfg struct {
+	Recipients []string `yaml:"recipients,omitempty"` // age1... public keys of the team
+	Identity   string   `yaml:"identity,omitempty"`   // your private key file (default: user config dir)
+}
+
+// Mount bind-mounts a host directory (or file) into the docroot of the php
+// and web containers, e.g. a plugin repo checked out next to the project.
+type Mount struct {
+	Host     string `yaml:"host"`         // absolute, ~/..., or relative to the project
+	Path     string `yaml:"path"`         // relative to the docroot, e.g. wp-content/plugins/my-plugin
+	ReadOnly bool   `yaml:"ro,omitempty"` // read-only for php too; web always mounts read-only
 }
 
 type TLSCfg struct {
 	Enabled bool `yaml:"enabled"` // on/off (mkcert when true)
 }
 
+// XdebugMode is the xdebug.mode PHP runs with.
+func (c *Config) XdebugMode() string {
+	switch {
+	case c.Xdebug.Mode != "":
+		return c.Xdebug.Mode
+	case c.Xdebug.Enabled:
+		return "debug,develop"
+	}
+	return "off"
+}
+
+// XdebugRelease pins the Xdebug the PHP image builds; 3.1 is the last
+// release that supports PHP 7.
+func (c *Config) XdebugRelease() string {
+	if strings.HasPrefix(c.Web.PHP, "7.") {
+		return "3.1.6"
+	}
+	return "3.4.2"
+}
+
+func (c *Config) XdebugClientHost() string {
+	return orDefault(c.Xdebug.ClientHost, "host.docker.internal")
+}
+
+func (c *Config) XdebugClientPort() int {
+	if c.Xdebug.ClientPort > 0 {
+		return c.Xdebug.ClientPort
+	}
+	return 9003
+}
+
+// HostGateway reports whether compose must map host.docker.internal itself:
+// Docker Desktop provides the name, Docker Engine on Linux does not.
+func (c *Config) HostGateway() bool {
+	return runtime.GOOS == "linux" && c.XdebugClientHost() == "host.docker.internal"
+}
+
+// IDEServerName is the PHP_IDE_CONFIG server name; PhpStorm matches path
+// mappings by it.
+func (c *Config) IDEServerName() string {
+	return orDefault(c.Domain, "wpdev")
+}
+
+// siteURL is the local URL the stack is served on.
+func siteURL(cfg *Config) string {
+	if cfg.TLS.Enabled {
+		return "https://" + cfg.Domain
+	}
+	return "http://" + cfg.Domain
+}
+
 func loadConfig(path string) (*Config, error) {
 	b, err := os.ReadFile(path)
 	if err != nil { return &Config{}, nil } // allow missing (init)
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPackageSpecYAML(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		in   string
+		want PackageSpec
+		out  string // marshalled back; in when empty
+	}{
+		{name: "slug", in: "woocommerce", want: PackageSpec{Source: "woocommerce"}},
+		{name: "pinned slug", in: "woocommerce@9.3.3", want: PackageSpec{Source: "woocommerce", Version: "9.3.3"}},
+		{name: "zip keeps @", in: "./vendor/acme@2.zip", want: PackageSpec{Source: "./vendor/acme@2.zip"}},
+		{name: "dir keeps @", in: "plugins/acme@dev", want: PackageSpec{Source: "plugins/acme@dev"}},
+		{
+			name: "mapping",
+			in:   "source: query-monitor\nversion: 3.16.4\nactive: false\n",
+			want: PackageSpec{Source: "query-monitor", Version: "3.16.4", Active: &no},
+		},
+		{
+			name: "mapping without active is written short",
+			in:   "source: query-monitor\nversion: 3.16.4\n",
+			want: PackageSpec{Source: "query-monitor", Version: "3.16.4"},
+			out:  "query-monitor@3.16.4",
+		},
+		{
+			name: "active zip",
+			in:   "source: acme.zip\nactive: true\n",
+			want: PackageSpec{Source: "acme.zip", Active: &yes},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PackageSpec
+			if err := yaml.Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Source != tt.want.Source || got.Version != tt.want.Version || (got.Active == nil) != (tt.want.Active == nil) ||
+				(got.Active != nil && *got.Active != *tt.want.Active) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			b, err := yaml.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.out
+			if want == "" {
+				want = tt.in
+			}
+			if string(b) != want && string(b) != want+"\n" {
+				t.Errorf("marshalled %q, want %q", b, want)
+			}
+		})
+	}
+}
+
+func TestPackageSpecList(t *testing.T) {
+	var wp WordPressCfg
+	in := "plugins:\n  - woocommerce@9.3.3\n  - source: ./plugins/acme\n    active: false\n"
+	if err := yaml.Unmarshal([]byte(in), &wp); err != nil {
+		t.Fatal(err)
+	}
+	if len(wp.Plugins) != 2 || wp.Plugins[0].Version != "9.3.3" || wp.Plugins[1].kind() != "dir" ||
+		wp.Plugins[1].Active == nil || *wp.Plugins[1].Active {
+		t.Errorf("got %+v", wp.Plugins)
+	}
+}
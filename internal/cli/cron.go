@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var cronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Inspect and run WP-Cron events",
+}
+
+var cronListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled WP-Cron events",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		c := wpExec(cfg, false, "cron", "event", "list", "--fields=hook,next_run_relative,recurrence")
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		return exitWith(c.Run())
+	},
+}
+
+var cronRunDue bool
+
+var cronRunCmd = &cobra.Command{
+	Use:   "run [hook...]",
+	Short: "Run WP-Cron events now, by hook or all that are due",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !cronRunDue {
+			return cmd.Usage()
+		}
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		a := append([]string{"cron", "event", "run"}, args...)
+		if cronRunDue {
+			a = append(a, "--due-now")
+		}
+		c := wpExec(cfg, false, a...)
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		return exitWith(c.Run())
+	},
+}
+
+func init() {
+	cronRunCmd.Flags().BoolVar(&cronRunDue, "due-now", false, "run every event that is due")
+	cronCmd.AddCommand(cronListCmd, cronRunCmd)
+	rootCmd.AddCommand(cronCmd)
+}
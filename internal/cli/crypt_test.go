@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestScratchFile(t *testing.T) {
+	secret := bytes.Repeat([]byte("INSERT INTO `wp_users` VALUES (1,'alice@client.com');\n"), 1000)
+	s, err := newScratchFile(t.TempDir(), "dump-*.age", func(w io.Writer) error {
+		_, err := w.Write(secret)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Remove()
+
+	raw, err := os.ReadFile(s.f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("alice@client.com")) {
+		t.Error("scratch file holds plaintext")
+	}
+	if s.size != int64(len(secret)) {
+		t.Errorf("size = %d, want %d", s.size, len(secret))
+	}
+	// Readable more than once, from the start
+	for i := 0; i < 2; i++ {
+		r, err := s.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Fatalf("read %d: got %d bytes back, want %d", i, len(got), len(secret))
+		}
+	}
+
+	name := s.f.Name()
+	s.Remove()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Remove left %s behind", name)
+	}
+}
@@ -1,10 +1,15 @@
 package cli
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -19,13 +24,35 @@ var dbDumpCmd = &cobra.Command{
 	Use:   "dump",
 	Short: "Dump database to .wpdev/db/dump.sql",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		sanitize, _ := cmd.Flags().GetBool("sanitize")
+		var san *Sanitizer
+		if sanitize {
+			if san, err = newSanitizer(cfg.Database.Sanitize); err != nil { return err }
+		}
+
 		_ = os.MkdirAll(".wpdev/db", 0o755)
-		path := filepath.Join(".wpdev", "db", fmt.Sprintf("dump-%s.sql", time.Now().Format("20060102-150405")))
-		c := exec.Command("docker", "compose", "exec", "-T", "db",
-			"sh", "-lc", "mysqldump -u root -proot --databases wordpress > /tmp/dump.sql && cat /tmp/dump.sql")
-		out, err := c.Output()
+		name := fmt.Sprintf("dump-%s.sql", time.Now().Format("20060102-150405"))
+		if sanitize {
+			name = fmt.Sprintf("dump-%s-sanitized.sql", time.Now().Format("20060102-150405"))
+		}
+		mode := os.FileMode(0o644)
+		if encryptionEnabled(cfg) {
+			name, mode = name+".age", 0o600
+		}
+		path := filepath.Join(".wpdev", "db", name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 		if err != nil { return err }
-		if err := os.WriteFile(path, out, 0o644); err != nil { return err }
+		defer f.Close()
+
+		w, err := encryptTo(cfg, f)
+		if err == nil { err = dbDump(cfg, w, san) }
+		if err == nil { err = w.Close() }
+		if err != nil {
+			_ = os.Remove(path)
+			return err
+		}
 		fmt.Println("Wrote", path)
 		return nil
 	},
@@ -33,24 +60,189 @@ var dbDumpCmd = &cobra.Command{
 
 var dbImportCmd = &cobra.Command{
 	Use:   "import <path.sql>",
-	Short: "Import SQL dump into DB",
+	Short: "Import SQL dump into DB (age-encrypted dumps are decrypted)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
 		path := args[0]
 		f, err := os.Open(path)
 		if err != nil { return err }
 		defer f.Close()
-		// Stream file into container to /tmp/dump.sql, then import.
-		sh := "cat > /tmp/dump.sql && mysql -u root -proot < /tmp/dump.sql"
-		c := exec.Command("docker", "compose", "exec", "-T", "db", "sh", "-lc", sh)
-		c.Stdin = f
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
+		r, err := decryptFrom(cfg, f)
+		if err != nil { return err }
+		return dbImport(cfg, r)
+	},
+}
+
+// dbDump streams a dump of the wordpress database from the db service into w,
+// passing it through san when non-nil.
+func dbDump(cfg *Config, w io.Writer, san *Sanitizer) error {
+	// --complete-insert gives the sanitizer column names to match rules against.
+	dump := dbDumpBin(cfg) + " -u root -proot --databases wordpress"
+	if san != nil {
+		dump += " --complete-insert"
+	}
+	c := exec.Command("docker", "compose", "exec", "-T", "db",
+		"sh", "-lc", dump+" > /tmp/dump.sql && cat /tmp/dump.sql")
+	c.Stderr = os.Stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil { return err }
+	if err := c.Start(); err != nil { return err }
+
+	if san != nil {
+		err = san.Copy(w, stdout)
+	} else {
+		_, err = io.Copy(w, stdout)
+	}
+	if err != nil { _ = c.Process.Kill() }
+	if werr := c.Wait(); err == nil { err = werr }
+	return err
+}
+
+// dbImport streams r into the db service and imports it as root into the
+// wordpress database (dumps made with --databases select it themselves).
+func dbImport(cfg *Config, r io.Reader) error {
+	// Stream file into container to /tmp/dump.sql, then import.
+	sh := "cat > /tmp/dump.sql && " + dbClientBin(cfg) + " -u root -proot wordpress < /tmp/dump.sql"
+	c := exec.Command("docker", "compose", "exec", "-T", "db", "sh", "-lc", sh)
+	c.Stdin = r
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+var dbShellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Open an interactive SQL client on the wordpress database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		c := exec.Command("docker", "compose", "exec", "db", dbClientBin(cfg), "-u", "root", "-proot", "wordpress")
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
 		return c.Run()
 	},
 }
 
+var dbQueryCmd = &cobra.Command{
+	Use:   "query <sql>",
+	Short: "Run a SQL query and print the result as table, csv or json",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "table" && format != "csv" && format != "json" {
+			return fmt.Errorf("unknown format %q, use table|csv|json", format)
+		}
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+
+		cols, rows, err := dbQuery(cfg, args[0])
+		if err != nil { return err }
+		return writeRows(os.Stdout, format, cols, rows)
+	},
+}
+
+// dbClientBin returns the SQL client shipped in the db image. MariaDB 11
+// images no longer include the mysql compatibility symlinks, and MariaDB
+// before 10.5 only has the mysql names.
+func dbClientBin(cfg *Config) string {
+	if mariadbNames(cfg) {
+		return "mariadb"
+	}
+	return "mysql"
+}
+
+func dbDumpBin(cfg *Config) string {
+	if mariadbNames(cfg) {
+		return "mariadb-dump"
+	}
+	return "mysqldump"
+}
+
+func mariadbNames(cfg *Config) bool {
+	if cfg.Database.Engine == "mysql" {
+		return false
+	}
+	var major, minor int
+	fmt.Sscanf(cfg.Database.Version, "%d.%d", &major, &minor)
+	return major == 0 || major > 10 || (major == 10 && minor >= 5)
+}
+
+// dbQuery runs sql in the db service in batch mode and returns the header
+// and rows of the (last) result set. NULL is returned as the string "NULL".
+func dbQuery(cfg *Config, sql string) ([]string, [][]string, error) {
+	c := exec.Command("docker", "compose", "exec", "-T", "db",
+		dbClientBin(cfg), "-u", "root", "-proot", "--batch", "wordpress", "-e", sql)
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil { return nil, nil, err }
+
+	var cols []string
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line == "" { continue }
+		fields := strings.Split(line, "\t")
+		for i, f := range fields {
+			fields[i] = batchUnescape(f)
+		}
+		if cols == nil {
+			cols = fields
+			continue
+		}
+		rows = append(rows, fields)
+	}
+	return cols, rows, nil
+}
+
+func batchUnescape(s string) string {
+	if !strings.Contains(s, `\`) { return s }
+	r := strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t", `\0`, "\x00")
+	return r.Replace(s)
+}
+
+func writeRows(w io.Writer, format string, cols []string, rows [][]string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if cols != nil {
+			if err := cw.Write(cols); err != nil { return err }
+		}
+		if err := cw.WriteAll(rows); err != nil { return err }
+		return cw.Error()
+	case "json":
+		objs := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			o := map[string]any{}
+			for i, c := range cols {
+				if i >= len(row) { break }
+				if row[i] == "NULL" {
+					o[c] = nil
+				} else {
+					o[c] = row[i]
+				}
+			}
+			objs = append(objs, o)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objs)
+	}
+	if cols == nil { return nil }
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil { return err }
+	fmt.Fprintf(w, "(%d rows)\n", len(rows))
+	return nil
+}
+
 func init() {
+	dbQueryCmd.Flags().String("format", "table", "output format: table|csv|json")
+	dbCmd.AddCommand(dbShellCmd)
+	dbCmd.AddCommand(dbQueryCmd)
+	dbDumpCmd.Flags().Bool("sanitize", false, "anonymize the dump using database.sanitize rules from .wpdev.yml")
 	dbCmd.AddCommand(dbDumpCmd)
 	dbCmd.AddCommand(dbImportCmd)
 }
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// branchStatePath records which git branch the live database belongs to.
+var branchStatePath = filepath.Join(".wpdev", "db", "branch.yml")
+
+type branchState struct {
+	Branch string `yaml:"branch"`
+}
+
+var dbBranchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "Show which git branch the database belongs to",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		if !cfg.Database.PerBranch {
+			fmt.Println("database.per_branch is off in .wpdev.yml")
+		}
+		git, err := gitBranch()
+		if err != nil { return err }
+		fmt.Println("git branch:     ", git)
+		fmt.Println("database branch:", loadBranchState())
+
+		metas, err := listSnapshots()
+		if err != nil { return err }
+		for _, m := range metas {
+			if b, ok := branchOfSnapshot(m.Name); ok {
+				fmt.Println("  saved:", b)
+			}
+		}
+		return nil
+	},
+}
+
+var dbBranchSwitchCmd = &cobra.Command{
+	Use:   "switch [branch]",
+	Short: "Save the database for the current branch and load the one for [branch] (default: git branch)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		} else if target, err = gitBranch(); err != nil {
+			return err
+		}
+		return switchDBBranch(cfg, target)
+	},
+}
+
+func init() {
+	dbBranchCmd.AddCommand(dbBranchSwitchCmd)
+	dbCmd.AddCommand(dbBranchCmd)
+}
+
+var errDetachedHead = errors.New("git HEAD is detached; check out a branch or name one: wpdev db branch switch <branch>")
+
+func gitBranch() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot detect git branch (is this a git repository?)")
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "HEAD" {
+		return "", errDetachedHead
+	}
+	return branch, nil
+}
+
+func loadBranchState() string {
+	var st branchState
+	b, err := os.ReadFile(branchStatePath)
+	if err != nil { return "" }
+	_ = yaml.Unmarshal(b, &st)
+	return st.Branch
+}
+
+func saveBranchState(branch string) error {
+	b, err := yaml.Marshal(branchState{Branch: branch})
+	if err != nil { return err }
+	_ = os.MkdirAll(filepath.Dir(branchStatePath), 0o755)
+	return os.WriteFile(branchStatePath, b, 0o644)
+}
+
+// branchSnapshot hex-encodes the branch name: git allows characters that are
+// not safe in snapshot names, and any shorter mapping lets two branches share
+// (and overwrite) one snapshot.
+func branchSnapshot(branch string) string {
+	return "branch-" + hex.EncodeToString([]byte(branch))
+}
+
+func branchOfSnapshot(name string) (string, bool) {
+	h, ok := strings.CutPrefix(name, "branch-")
+	if !ok {
+		return "", false
+	}
+	b, err := hex.DecodeString(h)
+	if err != nil { return "", false }
+	return string(b), true
+}
+
+// switchDBBranch stores the live database as the snapshot of the branch it
+// belongs to and swaps in target's snapshot. A branch seen for the first time
+// starts from the main branch's state.
+func switchDBBranch(cfg *Config, target string) error {
+	cur := loadBranchState()
+	if cur == "" {
+		fmt.Println("Database now tracks branch", target)
+		return saveBranchState(target)
+	}
+	if cur == target {
+		return nil
+	}
+	main := cfg.Database.MainBranch
+	if main == "" {
+		main = "main"
+	}
+
+	fmt.Printf("Switching database from branch %s to %s...\n", cur, target)
+	if err := waitForDB(cfg); err != nil { return err }
+	if _, err := createSnapshot(cfg, branchSnapshot(cur), true); err != nil { return err }
+
+	from := target
+	if _, err := loadSnapshot(branchSnapshot(target)); err != nil {
+		from = main
+		if cur == main {
+			fmt.Printf("New branch %s: keeping the %s database as its starting point.\n", target, main)
+			return saveBranchState(target)
+		}
+		if _, err := loadSnapshot(branchSnapshot(main)); err != nil {
+			fmt.Printf("Warning: no saved database for %s or %s; keeping the %s database.\n", target, main, cur)
+			return saveBranchState(target)
+		}
+		fmt.Printf("New branch %s: seeding from %s.\n", target, main)
+	}
+	if err := restoreSnapshot(cfg, branchSnapshot(from), false); err != nil { return err }
+	return saveBranchState(target)
+}
@@ -0,0 +1,23 @@
+package cli
+
+import "testing"
+
+func TestBranchSnapshot(t *testing.T) {
+	seen := map[string]string{}
+	for _, branch := range []string{"main", "feature/x", "feature__x", "feature-x", "fix/$(id);`x`", "ünïcode"} {
+		name := branchSnapshot(branch)
+		if err := checkSnapshotName(name); err != nil {
+			t.Errorf("branchSnapshot(%q) = %q: %v", branch, name, err)
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("branches %q and %q share snapshot %q", branch, other, name)
+		}
+		seen[name] = branch
+		if got, ok := branchOfSnapshot(name); !ok || got != branch {
+			t.Errorf("branchOfSnapshot(%q) = %q, %v, want %q", name, got, ok, branch)
+		}
+	}
+	if _, ok := branchOfSnapshot("before-upgrade"); ok {
+		t.Error("branchOfSnapshot accepted a manual snapshot")
+	}
+}
@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// engineRecordPath remembers which image created the database data dir.
+var engineRecordPath = filepath.Join(".wpdev", "db", "engine.yml")
+
+type engineRecord struct {
+	Engine   string `yaml:"engine"`
+	Version  string `yaml:"version"`
+	Persist  string `yaml:"persist"`
+	DataPath string `yaml:"data_path,omitempty"`
+}
+
+func (r engineRecord) String() string { return r.Engine + ":" + r.Version }
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database data dir to the engine/version in .wpdev.yml",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		rec, ok := loadEngineRecord()
+		if !ok {
+			return fmt.Errorf("no engine record in %s; nothing to migrate from", engineRecordPath)
+		}
+		if !engineChanged(rec, cfg) {
+			fmt.Println("Database already runs", rec.String()+"; nothing to do.")
+			return nil
+		}
+		return migrateDatabase(rec, cfg)
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbMigrateCmd)
+}
+
+func loadEngineRecord() (engineRecord, bool) {
+	var rec engineRecord
+	b, err := os.ReadFile(engineRecordPath)
+	if err != nil { return rec, false }
+	if err := yaml.Unmarshal(b, &rec); err != nil || rec.Engine == "" { return rec, false }
+	return rec, true
+}
+
+func saveEngineRecord(cfg *Config) error {
+	rec := engineRecord{
+		Engine:   cfg.Database.Engine,
+		Version:  cfg.Database.Version,
+		Persist:  cfg.Database.Persist,
+		DataPath: cfg.Database.DataPath,
+	}
+	b, err := yaml.Marshal(rec)
+	if err != nil { return err }
+	_ = os.MkdirAll(filepath.Dir(engineRecordPath), 0o755)
+	return os.WriteFile(engineRecordPath, b, 0o644)
+}
+
+func engineChanged(rec engineRecord, cfg *Config) bool {
+	return rec.Engine != cfg.Database.Engine || rec.Version != cfg.Database.Version
+}
+
+// checkDBEngine runs before start/rebuild. When the configured engine or
+// version no longer matches the one that created the data dir it offers to
+// migrate, and refuses to continue otherwise.
+func checkDBEngine(cfg *Config) error {
+	rec, ok := loadEngineRecord()
+	if !ok || !engineChanged(rec, cfg) {
+		return nil
+	}
+	// A different data dir or persistence mode starts from scratch anyway.
+	if rec.Persist != cfg.Database.Persist || rec.DataPath != cfg.Database.DataPath {
+		return nil
+	}
+	if cfg.Database.Persist == "bind" && dirEmpty(cfg.Database.DataPath) {
+		return nil
+	}
+	if cfg.Database.Persist != "bind" {
+		// Only bind data can be migrated here; once the user removed the
+		// volume the new engine starts fresh and the record is updated.
+		if dbVolumeMissing() {
+			return nil
+		}
+		return fmt.Errorf("database data was created by %s but .wpdev.yml now asks for %s:%s; %s",
+			rec.String(), cfg.Database.Engine, cfg.Database.Version, volumeMigrateHint)
+	}
+
+	fmt.Printf("Database data was created by %s but .wpdev.yml now asks for %s:%s.\n",
+		rec.String(), cfg.Database.Engine, cfg.Database.Version)
+	ans := strings.ToLower(prompt("Migrate the data now (dump, move aside, import)? (y/n)", "y"))
+	if ans != "y" && ans != "yes" {
+		return fmt.Errorf("refusing to start %s:%s on %s data; run `wpdev db migrate` or revert database.version/engine",
+			cfg.Database.Engine, cfg.Database.Version, rec.String())
+	}
+	return migrateDatabase(rec, cfg)
+}
+
+// migrateDatabase dumps with the old image, moves the old data dir aside,
+// starts the new image on an empty dir, imports and compares row counts.
+func migrateDatabase(rec engineRecord, cfg *Config) error {
+	if cfg.Database.Persist != "bind" {
+		return fmt.Errorf("%s", volumeMigrateHint)
+	}
+	// The dump is encrypted like any other; make sure it can be read back
+	// before the old data is moved aside
+	if encryptionEnabled(cfg) {
+		if _, err := loadIdentities(cfg); err != nil { return err }
+	}
+	oldCfg := *cfg
+	oldCfg.Database.Engine = rec.Engine
+	oldCfg.Database.Version = rec.Version
+
+	fmt.Println("Starting", rec.String(), "to dump existing data...")
+	if err := renderTemplates(&oldCfg); err != nil { return err }
+	if err := composeRun("up", "-d", "db"); err != nil { return err }
+	if err := waitForDB(&oldCfg); err != nil { return err }
+
+	before, err := dbRowCounts(&oldCfg)
+	if err != nil { return err }
+
+	ts := time.Now().Format("20060102-150405")
+	dumpPath := filepath.Join(".wpdev", "db", fmt.Sprintf("migrate-%s-%s.sql", rec.Engine+rec.Version, ts))
+	if encryptionEnabled(cfg) {
+		dumpPath += ".age"
+	}
+	f, err := os.OpenFile(dumpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil { return err }
+	w, err := encryptTo(cfg, f)
+	if err == nil {
+		sw := &sandboxLineWriter{w: w}
+		err = dbDump(&oldCfg, sw, nil)
+		if cerr := sw.Close(); err == nil { err = cerr }
+		if cerr := w.Close(); err == nil { err = cerr }
+	}
+	if cerr := f.Close(); err == nil { err = cerr }
+	if err != nil { return err }
+	fmt.Println("Wrote", dumpPath)
+
+	if err := composeRun("rm", "-sf", "db"); err != nil { return err }
+	aside := fmt.Sprintf("%s.%s-%s.bak-%s", filepath.Clean(cfg.Database.DataPath), rec.Engine, rec.Version, ts)
+	if err := os.Rename(cfg.Database.DataPath, aside); err != nil { return err }
+	if err := os.MkdirAll(cfg.Database.DataPath, 0o755); err != nil { return err }
+	fmt.Println("Moved old data dir to", aside)
+
+	fmt.Printf("Starting %s:%s on a fresh data dir...\n", cfg.Database.Engine, cfg.Database.Version)
+	if err := renderTemplates(cfg); err != nil { return err }
+	if err := composeRun("up", "-d", "db"); err != nil { return err }
+	if err := waitForDB(cfg); err != nil { return err }
+
+	in, err := os.Open(dumpPath)
+	if err != nil { return err }
+	r, err := decryptFrom(cfg, in)
+	if err == nil { err = dbImport(cfg, r) }
+	in.Close()
+	if err != nil { return err }
+
+	after, err := dbRowCounts(cfg)
+	if err != nil { return err }
+	var bad []string
+	for t, n := range before {
+		if after[t] != n {
+			bad = append(bad, fmt.Sprintf("%s: %d -> %d", t, n, after[t]))
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("row counts differ after migration (old data kept in %s):\n  %s", aside, strings.Join(bad, "\n  "))
+	}
+	fmt.Printf("Verified row counts for %d tables.\n", len(before))
+
+	if err := saveEngineRecord(cfg); err != nil { return err }
+	fmt.Println("Migration complete. Old data dir kept in", aside)
+	return nil
+}
+
+func composeRun(args ...string) error {
+	c := exec.Command("docker", append([]string{"compose"}, args...)...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// waitForDB polls over TCP; the image's first-run bootstrap server only
+// listens on the socket, so this waits for the real server.
+func waitForDB(cfg *Config) error {
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		c := exec.Command("docker", "compose", "exec", "-T", "db",
+			dbClientBin(cfg), "-h", "127.0.0.1", "-u", "root", "-proot", "-e", "SELECT 1")
+		if err := c.Run(); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("database did not become ready; see `docker compose logs db`")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// dbRowCounts returns exact row counts for every table in wordpress.
+func dbRowCounts(cfg *Config) (map[string]int, error) {
+	_, rows, err := dbQuery(cfg, "SELECT table_name FROM information_schema.tables WHERE table_schema = 'wordpress' AND table_type = 'BASE TABLE'")
+	if err != nil { return nil, err }
+	counts := map[string]int{}
+	if len(rows) == 0 { return counts, nil }
+
+	var parts []string
+	for _, r := range rows {
+		t := strings.ReplaceAll(r[0], "`", "``")
+		parts = append(parts, fmt.Sprintf("SELECT '%s', COUNT(*) FROM `%s`", sqlEscape(r[0]), t))
+	}
+	_, rows, err = dbQuery(cfg, strings.Join(parts, " UNION ALL "))
+	if err != nil { return nil, err }
+	for _, r := range rows {
+		var n int
+		fmt.Sscan(r[1], &n)
+		counts[r[0]] = n
+	}
+	return counts, nil
+}
+
+const volumeMigrateHint = "automated migration needs database.persist: bind; dump with `wpdev db dump`, " +
+	"remove the dbdata volume (`docker compose down -v`), start and import the dump with `wpdev db import`"
+
+// dbVolumeMissing reports whether compose's dbdata volume is gone. When
+// docker can't tell, the volume is assumed to exist.
+func dbVolumeMissing() bool {
+	out, err := exec.Command("docker", "compose", "config", "--format", "json").Output()
+	if err != nil { return false }
+	var project struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(out, &project); err != nil || project.Name == "" { return false }
+	out, err = exec.Command("docker", "volume", "ls", "-q",
+		"--filter", "label=com.docker.compose.project="+project.Name,
+		"--filter", "label=com.docker.compose.volume=dbdata").Output()
+	if err != nil { return false }
+	return strings.TrimSpace(string(out)) == ""
+}
+
+func dirEmpty(path string) bool {
+	entries, err := os.ReadDir(path)
+	return err != nil || len(entries) == 0
+}
+
+// sandboxLineWriter strips the "/*M!999999\- enable the sandbox mode */"
+// line newer MariaDB dumps start with; the MySQL client rejects it.
+type sandboxLineWriter struct {
+	w    io.Writer
+	head []byte
+	done bool
+}
+
+func (s *sandboxLineWriter) Write(b []byte) (int, error) {
+	if s.done {
+		return s.w.Write(b)
+	}
+	s.head = append(s.head, b...)
+	i := bytes.IndexByte(s.head, '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	s.done = true
+	rest := s.head
+	if bytes.HasPrefix(rest, []byte("/*M!999999")) {
+		rest = rest[i+1:]
+	}
+	if _, err := s.w.Write(rest); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close writes out a first line that never got its newline.
+func (s *sandboxLineWriter) Close() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	if bytes.HasPrefix(s.head, []byte("/*M!999999")) {
+		return nil
+	}
+	_, err := s.w.Write(s.head)
+	return err
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var snapshotDir = filepath.Join(".wpdev", "snapshots")
+
+// snapshotImage streams raw data files; it runs with --volumes-from the db
+// container so bind mounts and the dbdata volume are handled the same way.
+const snapshotImage = "alpine:3"
+
+type snapshotMeta struct {
+	Name    string    `yaml:"name"`
+	Kind    string    `yaml:"kind"` // fs (raw data dir) | sql (dump)
+	File    string    `yaml:"file"`
+	Engine  string    `yaml:"engine"`
+	Version string    `yaml:"version"`
+	Persist string    `yaml:"persist"`
+	Created time.Time `yaml:"created"`
+}
+
+var dbSnapshotCmd = &cobra.Command{
+	Use:   "snapshot [name]",
+	Short: "Save a database snapshot (--fast copies the raw data dir)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		fast, _ := cmd.Flags().GetBool("fast")
+		name := time.Now().Format("20060102-150405")
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if _, err := os.Stat(filepath.Join(snapshotDir, name+".yml")); err == nil {
+			return fmt.Errorf("snapshot %q already exists", name)
+		}
+		meta, err := createSnapshot(cfg, name, fast)
+		if err != nil { return err }
+		fmt.Println("Wrote", filepath.Join(snapshotDir, meta.File))
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore a database snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		force, _ := cmd.Flags().GetBool("force")
+		return restoreSnapshot(cfg, args[0], force)
+	},
+}
+
+var dbSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List database snapshots",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		metas, err := listSnapshots()
+		if err != nil { return err }
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tKIND\tENGINE\tCREATED\tSIZE")
+		for _, m := range metas {
+			size := "-"
+			if st, err := os.Stat(filepath.Join(snapshotDir, m.File)); err == nil {
+				size = humanBytes(st.Size())
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s:%s\t%s\t%s\n", m.Name, m.Kind, m.Engine, m.Version, m.Created.Format("2006-01-02 15:04"), size)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	dbSnapshotCmd.Flags().Bool("fast", false, "stop the db and archive the raw data dir/volume instead of dumping SQL")
+	dbRestoreCmd.Flags().Bool("force", false, "restore a raw snapshot taken with a different database version")
+	dbCmd.AddCommand(dbSnapshotCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbSnapshotsCmd)
+}
+
+// snapshotNameRe keeps names usable as file names and safe to hand to the
+// data dir container's shell.
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func checkSnapshotName(name string) error {
+	if !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+func createSnapshot(cfg *Config, name string, fast bool) (*snapshotMeta, error) {
+	if err := checkSnapshotName(name); err != nil { return nil, err }
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil { return nil, err }
+	meta := &snapshotMeta{
+		Name:    name,
+		Kind:    "sql",
+		File:    name + ".sql",
+		Engine:  cfg.Database.Engine,
+		Version: cfg.Database.Version,
+		Persist: cfg.Database.Persist,
+		Created: time.Now(),
+	}
+	if fast {
+		meta.Kind = "fs"
+		meta.File = name + ".tar.gz"
+	}
+	if encryptionEnabled(cfg) {
+		meta.File += ".age"
+	}
+	// Both kinds are encrypted while they stream, so no plaintext copy ever
+	// lands on disk
+	path := filepath.Join(snapshotDir, meta.File)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil { return nil, err }
+	w, err := encryptTo(cfg, f)
+	if err == nil && fast {
+		var id string
+		if id, err = dbContainerID(); err == nil {
+			if err = composeRun("stop", "db"); err == nil {
+				err = dataDirRun(id, nil, w, "tar czf - -C /var/lib/mysql .")
+				if serr := composeRun("start", "db"); err == nil { err = serr }
+			}
+		}
+	} else if err == nil {
+		err = dbDump(cfg, w, nil)
+	}
+	if err == nil { err = w.Close() }
+	if cerr := f.Close(); err == nil { err = cerr }
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	b, err := yaml.Marshal(meta)
+	if err != nil { return nil, err }
+	return meta, os.WriteFile(filepath.Join(snapshotDir, name+".yml"), b, 0o644)
+}
+
+func restoreSnapshot(cfg *Config, name string, force bool) error {
+	meta, err := loadSnapshot(name)
+	if err != nil { return err }
+	if err := checkSnapshotName(meta.File); err != nil { return err }
+	path := filepath.Join(snapshotDir, meta.File)
+
+	if meta.Kind == "sql" {
+		f, err := os.Open(path)
+		if err != nil { return err }
+		defer f.Close()
+		r, err := decryptFrom(cfg, f)
+		if err != nil { return err }
+		if err := dbImport(cfg, r); err != nil { return err }
+		fmt.Println("Restored snapshot", name)
+		return nil
+	}
+
+	// Raw data files only load into the engine (and version) that wrote them.
+	if meta.Engine != cfg.Database.Engine {
+		return fmt.Errorf("snapshot %q was taken with %s:%s; refusing to restore into %s:%s",
+			name, meta.Engine, meta.Version, cfg.Database.Engine, cfg.Database.Version)
+	}
+	if meta.Version != cfg.Database.Version && !force {
+		return fmt.Errorf("snapshot %q was taken with %s:%s but the project runs %s; use --force to restore anyway",
+			name, meta.Engine, meta.Version, cfg.Database.Version)
+	}
+	f, err := os.Open(path)
+	if err != nil { return err }
+	defer f.Close()
+	r, err := decryptFrom(cfg, f)
+	if err != nil { return err }
+
+	id, err := dbContainerID()
+	if err != nil { return err }
+	if err := composeRun("stop", "db"); err != nil { return err }
+	err = dataDirRun(id, r, nil, restoreDataDirScript)
+	if serr := composeRun("start", "db"); err == nil { err = serr }
+	if err != nil { return err }
+
+	// The data dir now belongs to whatever version wrote the snapshot.
+	rc := *cfg
+	rc.Database.Version = meta.Version
+	if err := saveEngineRecord(&rc); err != nil { return err }
+	fmt.Println("Restored snapshot", name)
+	return nil
+}
+
+func loadSnapshot(name string) (*snapshotMeta, error) {
+	if err := checkSnapshotName(name); err != nil { return nil, err }
+	b, err := os.ReadFile(filepath.Join(snapshotDir, name+".yml"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no snapshot named %q (see `wpdev db snapshots`)", name)
+	}
+	if err != nil { return nil, err }
+	var meta snapshotMeta
+	if err := yaml.Unmarshal(b, &meta); err != nil { return nil, err }
+	return &meta, nil
+}
+
+func listSnapshots() ([]*snapshotMeta, error) {
+	matches, _ := filepath.Glob(filepath.Join(snapshotDir, "*.yml"))
+	var metas []*snapshotMeta
+	for _, m := range matches {
+		name := strings.TrimSuffix(filepath.Base(m), ".yml")
+		if checkSnapshotName(name) != nil {
+			continue
+		}
+		meta, err := loadSnapshot(name)
+		if err != nil { return nil, err }
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Created.Before(metas[j].Created) })
+	return metas, nil
+}
+
+// dbContainerID returns the (possibly stopped) db container of this project.
+func dbContainerID() (string, error) {
+	out, err := exec.Command("docker", "compose", "ps", "-aq", "db").Output()
+	if err != nil { return "", err }
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return "", fmt.Errorf("no db container yet; run `wpdev start` first")
+	}
+	return id, nil
+}
+
+// restoreDataDirScript unpacks the archive on stdin next to the data and only
+// swaps it in once tar succeeded: a corrupt or truncated stream leaves the
+// current data as it was.
+const restoreDataDirScript = `set -e
+t=/var/lib/mysql/.wpdev-restore
+rm -rf "$t"
+mkdir "$t"
+if ! tar xzf - -C "$t"; then rm -rf "$t"; exit 1; fi
+find /var/lib/mysql -mindepth 1 -maxdepth 1 ! -name .wpdev-restore -exec rm -rf {} +
+find "$t" -mindepth 1 -maxdepth 1 -exec mv {} /var/lib/mysql/ \;
+rmdir "$t"`
+
+// dataDirRun runs the fixed sh -c script in a throwaway container that sees
+// the db container's /var/lib/mysql, with in and out as its stdin and stdout
+// (nil for none / the terminal).
+func dataDirRun(containerID string, in io.Reader, out io.Writer, script string) error {
+	c := exec.Command("docker", "run", "--rm", "-i", "--volumes-from", containerID, snapshotImage, "sh", "-c", script)
+	c.Stdin, c.Stdout, c.Stderr = in, out, os.Stderr
+	if out == nil {
+		c.Stdout = os.Stdout
+	}
+	return c.Run()
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,30 @@
+package cli
+
+import "testing"
+
+func TestCheckSnapshotName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"20240101-120000", true},
+		{"before-upgrade", true},
+		{"v6.6_rc.1", true},
+		{"before-upgrade.tar.gz.age", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"-rf", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"x;rm -rf /var/lib/mysql", false},
+		{"$(id)", false},
+		{"a`id`", false},
+		{"with space", false},
+	}
+	for _, tt := range tests {
+		if err := checkSnapshotName(tt.name); (err == nil) != tt.ok {
+			t.Errorf("checkSnapshotName(%q) = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// debugConstants are switched together by `wpdev debug on|off`.
+var debugConstants = []string{"WP_DEBUG", "WP_DEBUG_LOG", "WP_DEBUG_DISPLAY", "SCRIPT_DEBUG", "SAVEQUERIES"}
+
+var debugCmd = &cobra.Command{
+	Use:   "debug [on|off|status]",
+	Short: "Toggle WordPress debugging (WP_DEBUG, SCRIPT_DEBUG, SAVEQUERIES, display_errors)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		mode := "status"
+		if len(args) == 1 {
+			mode = args[0]
+		}
+		switch mode {
+		case "on", "off":
+			if _, err := os.Stat(wpConfigPath(cfg)); err != nil {
+				return fmt.Errorf("%s not found; `wpdev debug` needs an installed WordPress (see the wordpress: section of .wpdev.yml)", wpConfigPath(cfg))
+			}
+			cfg.WordPress.Debug = mode == "on"
+			if err := saveConfig(".wpdev.yml", cfg); err != nil { return err }
+			// PHP reads wp-config-wpdev.php on every request, so no restart is needed.
+			if err := syncWPConfig(cfg); err != nil { return err }
+			fmt.Println("Debugging", mode)
+			if cfg.WordPress.Debug {
+				fmt.Println("Follow the log with: wpdev debug log -f")
+			}
+			return nil
+		case "status":
+			return debugStatus(cfg)
+		default:
+			return fmt.Errorf("unknown mode %q, use on|off|status", mode)
+		}
+	},
+}
+
+var debugLogFollow bool
+var debugLogLines int
+
+var debugLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show wp-content/debug.log with PHP errors colourised and repeats folded",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		path := debugLogPath(cfg)
+		if _, err := os.Stat(path); os.IsNotExist(err) && !debugLogFollow {
+			return fmt.Errorf("%s does not exist yet (is debugging on? `wpdev debug on`)", path)
+		}
+		return tailDebugLog(path, debugLogLines, debugLogFollow, os.Stdout)
+	},
+}
+
+func init() {
+	debugLogCmd.Flags().BoolVarP(&debugLogFollow, "follow", "f", false, "keep reading as new entries are written")
+	debugLogCmd.Flags().IntVarP(&debugLogLines, "lines", "n", 20, "number of past entries to show (0 = all)")
+	debugCmd.AddCommand(debugLogCmd)
+	rootCmd.AddCommand(debugCmd)
+}
+
+func debugLogPath(cfg *Config) string {
+	return filepath.Join(orDefault(cfg.Web.Docroot, "."), "wp-content", "debug.log")
+}
+
+// debugStatus prints the configured state and, when the stack is running,
+// what WordPress actually sees (constants in wp-config.php take precedence).
+func debugStatus(cfg *Config) error {
+	state := "off"
+	if cfg.WordPress.Debug {
+		state = "on"
+	}
+	fmt.Println("wordpress.debug:", state)
+
+	php := `foreach (['` + strings.Join(debugConstants, `','`) + `'] as $c) { printf("%-16s %s\n", $c, defined($c) ? var_export(constant($c), true) : 'undefined'); } printf("%-16s %s\n", 'display_errors', ini_get('display_errors'));`
+	out, err := wpExec(cfg, false, "eval", php).Output()
+	if err != nil {
+		fmt.Println("(start the stack to see the values WordPress runs with)")
+		return nil
+	}
+	fmt.Print(string(out))
+	return nil
+}
+
+// ── debug.log ─────────────────────────────────────────────────────────────────
+
+// debugLogStart matches the timestamp PHP's error_log puts on every entry;
+// lines without it (stack traces) belong to the entry above.
+var debugLogStart = regexp.MustCompile(`^\[\d{2}-\w{3}-\d{4} \d{2}:\d{2}:\d{2}[^\]]*\] `)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+type logEntry struct {
+	stamp string
+	lines []string
+}
+
+// key identifies repeats: the first line without its timestamp.
+func (e *logEntry) key() string {
+	return e.lines[0]
+}
+
+// debugLogPrinter writes each distinct entry once and keeps counting the
+// repeats, which are reported by flushRepeats.
+type debugLogPrinter struct {
+	w      io.Writer
+	color  bool
+	seen   map[string]int
+	shown  map[string]int
+	order  []string
+	quiet  bool // collect only, used while reading the backlog
+	recent []*logEntry
+}
+
+func newDebugLogPrinter(w io.Writer) *debugLogPrinter {
+	return &debugLogPrinter{w: w, color: colorOutput(w), seen: map[string]int{}, shown: map[string]int{}}
+}
+
+func colorOutput(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *debugLogPrinter) add(e *logEntry) {
+	k := e.key()
+	p.seen[k]++
+	if p.seen[k] > 1 {
+		return
+	}
+	p.order = append(p.order, k)
+	if p.quiet {
+		p.recent = append(p.recent, e)
+		return
+	}
+	p.print(e)
+	p.shown[k] = 1
+}
+
+func (p *debugLogPrinter) print(e *logEntry) {
+	color := ""
+	if p.color {
+		color = debugLogColor(e.lines[0])
+	}
+	for i, l := range e.lines {
+		switch {
+		case !p.color:
+			if i == 0 && e.stamp != "" {
+				l = e.stamp + " " + l
+			}
+			fmt.Fprintln(p.w, l)
+		case i == 0:
+			fmt.Fprintf(p.w, "%s%s%s %s%s%s\n", ansiDim, e.stamp, ansiReset, color, l, ansiReset)
+		default:
+			fmt.Fprintf(p.w, "%s%s%s\n", ansiDim, l, ansiReset)
+		}
+	}
+}
+
+// showBacklog prints the last n distinct entries collected while quiet.
+func (p *debugLogPrinter) showBacklog(n int) {
+	p.quiet = false
+	from := 0
+	if n > 0 && len(p.recent) > n {
+		from = len(p.recent) - n
+		fmt.Fprintf(p.w, "(%d earlier entries skipped, use -n 0 to see all)\n", from)
+	}
+	for _, e := range p.recent[from:] {
+		p.print(e)
+	}
+	for _, e := range p.recent {
+		p.shown[e.key()] = 1
+	}
+	p.recent = nil
+	p.flushRepeats()
+}
+
+// flushRepeats reports entries that occurred again since they were shown.
+func (p *debugLogPrinter) flushRepeats() {
+	for _, k := range p.order {
+		n := p.seen[k]
+		if p.shown[k] == 0 || n == p.shown[k] {
+			continue
+		}
+		p.shown[k] = n
+		msg := k
+		if len(msg) > 100 {
+			msg = msg[:100] + "…"
+		}
+		if p.color {
+			fmt.Fprintf(p.w, "%s  ×%d %s%s\n", ansiDim, n, msg, ansiReset)
+		} else {
+			fmt.Fprintf(p.w, "  ×%d %s\n", n, msg)
+		}
+	}
+}
+
+func debugLogColor(line string) string {
+	switch {
+	case strings.HasPrefix(line, "PHP Fatal"), strings.HasPrefix(line, "PHP Parse"),
+		strings.HasPrefix(line, "PHP Recoverable"), strings.HasPrefix(line, "WordPress database error"):
+		return ansiRed
+	case strings.HasPrefix(line, "PHP Warning"):
+		return ansiYellow
+	case strings.HasPrefix(line, "PHP Notice"), strings.HasPrefix(line, "PHP Deprecated"), strings.HasPrefix(line, "PHP Strict"):
+		return ansiCyan
+	}
+	return ""
+}
+
+// tailDebugLog prints the last n distinct entries of path and, with follow,
+// keeps polling for new ones. A truncated or recreated file is read again
+// from the start.
+func tailDebugLog(path string, n int, follow bool, w io.Writer) error {
+	p := newDebugLogPrinter(w)
+	p.quiet = true
+
+	var cur *logEntry
+	emit := func() {
+		if cur != nil {
+			p.add(cur)
+			cur = nil
+		}
+	}
+	feed := func(line string) {
+		line = strings.TrimRight(line, "\r\n")
+		if m := debugLogStart.FindString(line); m != "" {
+			emit()
+			cur = &logEntry{stamp: strings.TrimSpace(m), lines: []string{line[len(m):]}}
+			return
+		}
+		if cur == nil {
+			cur = &logEntry{lines: []string{line}}
+			return
+		}
+		cur.lines = append(cur.lines, line)
+	}
+
+	var f *os.File
+	var r *bufio.Reader
+	var offset int64
+	partial := ""
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for {
+		if f == nil {
+			var err error
+			f, err = os.Open(path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if f != nil {
+				r, offset, partial = bufio.NewReader(f), 0, ""
+			}
+		}
+		if f != nil {
+			for {
+				s, err := r.ReadString('\n')
+				offset += int64(len(s))
+				if err == io.EOF {
+					partial += s
+					break
+				}
+				if err != nil { return err }
+				feed(partial + s)
+				partial = ""
+			}
+		}
+
+		// Stack traces are written in one go, so at EOF the entry is complete.
+		emit()
+		if p.quiet {
+			p.showBacklog(n)
+		} else {
+			p.flushRepeats()
+		}
+		if !follow {
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+		if st, err := os.Stat(path); err != nil || (f != nil && st.Size() < offset) {
+			if f != nil {
+				f.Close()
+				f = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// vscodeLaunchName identifies the launch.json entry wpdev owns; it is
+// replaced on every run, other entries are kept.
+const vscodeLaunchName = "wpdev: Listen for Xdebug"
+
+var xdebugIDECmd = &cobra.Command{
+	Use:   "ide vscode|phpstorm",
+	Short: "Write the IDE's Xdebug config with path mappings for the project and its mounts",
+	Long: `Write the IDE's Xdebug config with path mappings for the project and its mounts.
+
+vscode updates the "` + vscodeLaunchName + `" entry of .vscode/launch.json
+(PHP Debug extension). phpstorm adds a server named after the domain, which
+PHP_IDE_CONFIG points PhpStorm at, to .idea/workspace.xml; close the project
+in PhpStorm first, or it writes its own copy back.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"vscode", "phpstorm"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		maps, err := xdebugPathMappings(cfg)
+		if err != nil { return err }
+		switch args[0] {
+		case "vscode":
+			return writeVSCodeLaunch(cfg, maps)
+		case "phpstorm":
+			return writePhpStormServer(cfg, maps)
+		}
+		return fmt.Errorf("unknown IDE %q, use vscode or phpstorm", args[0])
+	},
+}
+
+func init() {
+	xdebugCmd.AddCommand(xdebugIDECmd)
+}
+
+// ideLocalPath writes host paths inside the project relative to the IDE's
+// project variable, so the generated config works from any checkout.
+func ideLocalPath(host, projectVar string) string {
+	wd, err := os.Getwd()
+	if err != nil { return host }
+	rel, err := filepath.Rel(wd, host)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(host)
+	}
+	if rel == "." {
+		return projectVar
+	}
+	return projectVar + "/" + filepath.ToSlash(rel)
+}
+
+func writeVSCodeLaunch(cfg *Config, maps []pathMapping) error {
+	pathMappings := map[string]string{}
+	for _, m := range maps {
+		pathMappings[m.Container] = ideLocalPath(m.Host, "${workspaceFolder}")
+	}
+	entry, err := json.Marshal(struct {
+		Name         string            `json:"name"`
+		Type         string            `json:"type"`
+		Request      string            `json:"request"`
+		Port         int               `json:"port"`
+		PathMappings map[string]string `json:"pathMappings"`
+	}{vscodeLaunchName, "php", "launch", cfg.XdebugClientPort(), pathMappings})
+	if err != nil { return err }
+
+	path := filepath.Join(".vscode", "launch.json")
+	launch := map[string]json.RawMessage{"version": json.RawMessage(`"0.2.0"`)}
+	var configs []json.RawMessage
+	if b, err := os.ReadFile(path); err == nil {
+		// launch.json is JSONC; comments or trailing commas are left to the user
+		if err := json.Unmarshal(b, &launch); err != nil {
+			return fmt.Errorf("%s is not plain JSON (%v); add this configuration yourself:\n%s", path, err, entry)
+		}
+		if raw, ok := launch["configurations"]; ok {
+			if err := json.Unmarshal(raw, &configs); err != nil { return fmt.Errorf("%s: %w", path, err) }
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	replaced := false
+	for i, c := range configs {
+		var named struct{ Name string `json:"name"` }
+		if json.Unmarshal(c, &named) == nil && named.Name == vscodeLaunchName {
+			configs[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		configs = append(configs, entry)
+	}
+	raw, err := json.Marshal(configs)
+	if err != nil { return err }
+	launch["configurations"] = raw
+
+	b, err := json.Marshal(launch)
+	if err != nil { return err }
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "    "); err != nil { return err }
+	out.WriteByte('\n')
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil { return err }
+	fmt.Printf("Wrote %q to %s (port %d).\n", vscodeLaunchName, path, cfg.XdebugClientPort())
+	printXdebugNextSteps(cfg)
+	return nil
+}
+
+var phpServersComponent = regexp.MustCompile(`(?s)<component name="PhpServers">.*?</component>|<component name="PhpServers"\s*/>`)
+
+func writePhpStormServer(cfg *Config, maps []pathMapping) error {
+	name := ideServerName(cfg)
+	port := 80
+	if cfg.TLS.Enabled {
+		port = 443
+	}
+	var server strings.Builder
+	fmt.Fprintf(&server, "      <server host=\"%s\" id=\"%s\" name=\"%s\" port=\"%d\" use_path_mappings=\"true\">\n",
+		html.EscapeString(cfg.Domain), newUUID(), html.EscapeString(name), port)
+	server.WriteString("        <path_mappings>\n")
+	for _, m := range maps {
+		fmt.Fprintf(&server, "          <mapping local-root=\"%s\" remote-root=\"%s\" />\n",
+			html.EscapeString(ideLocalPath(m.Host, "$PROJECT_DIR$")), html.EscapeString(m.Container))
+	}
+	server.WriteString("        </path_mappings>\n      </server>\n")
+
+	path := filepath.Join(".idea", "workspace.xml")
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		b, err = []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<project version=\"4\">\n</project>\n"), nil
+	}
+	if err != nil { return err }
+	ws := string(b)
+
+	// Keep other servers, replace ours
+	var servers []string
+	if comp := phpServersComponent.FindString(ws); comp != "" {
+		own := regexp.MustCompile(`^\s*<server\b[^>]*\bname="` + regexp.QuoteMeta(html.EscapeString(name)) + `"`)
+		for _, s := range regexp.MustCompile(`(?s)[ \t]*<server\b(?:[^>]*/>|.*?</server>)\n?`).FindAllString(comp, -1) {
+			if !own.MatchString(s) {
+				servers = append(servers, strings.TrimRight(s, "\n")+"\n")
+			}
+		}
+	}
+	servers = append(servers, server.String())
+	comp := "<component name=\"PhpServers\">\n    <servers>\n" + strings.Join(servers, "") + "    </servers>\n  </component>"
+
+	switch {
+	case phpServersComponent.MatchString(ws):
+		ws = phpServersComponent.ReplaceAllLiteralString(ws, comp)
+	case strings.Contains(ws, "</project>"):
+		i := strings.LastIndex(ws, "</project>")
+		ws = ws[:i] + "  " + comp + "\n" + ws[i:]
+	default:
+		return fmt.Errorf("%s has no <project> element; add this server yourself:\n%s", path, server.String())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
+	if err := os.WriteFile(path, []byte(ws), 0o644); err != nil { return err }
+	fmt.Printf("Wrote PhpStorm server %q to %s.\n", name, path)
+	fmt.Printf("Listen on port %d (Settings > PHP > Debug) and start listening for connections.\n", cfg.XdebugClientPort())
+	printXdebugNextSteps(cfg)
+	return nil
+}
+
+// ideServerName is the server name PHP actually sends: compose files rendered
+// from an older template still send "wpdev" rather than the domain.
+func ideServerName(cfg *Config) string {
+	if v, ok := loadRenderedCompose().Services["php"].env("PHP_IDE_CONFIG"); ok {
+		for _, kv := range strings.Fields(v) {
+			if name, ok := strings.CutPrefix(kv, "serverName="); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return cfg.IDEServerName()
+}
+
+func printXdebugNextSteps(cfg *Config) {
+	if !strings.Contains(cfg.XdebugMode(), "debug") {
+		fmt.Println("Turn step debugging on with: wpdev xdebug debug")
+	}
+	if err := checkHostGateway(cfg); err != nil {
+		fmt.Println("Warning:", err)
+	} else if cfg.HostGateway() {
+		fmt.Println("Xdebug connects from the Docker bridge, so the IDE must accept connections on it (not only 127.0.0.1).")
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -14,6 +14,9 @@ var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a wpdev project (interactive)",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if refresh, _ := cmd.Flags().GetBool("refresh-templates"); refresh {
+			return refreshTemplates()
+		}
 		fmt.Println("Welcome to wpdev init — press ENTER to accept defaults.")
 
 		name := prompt("Project name", "mysite")
@@ -38,6 +41,9 @@ var initCmd = &cobra.Command{
 		redisAns := strings.ToLower(prompt("Enable Redis? (y/n)", "y"))
 		mailpitAns := strings.ToLower(prompt("Enable Mailpit? (y/n)", "y"))
 		adminerAns := strings.ToLower(prompt("Enable Adminer? (y/n)", "y"))
+		cronAns := strings.ToLower(prompt("Run WP-Cron on a timer instead of on page loads? (y/n)", "n"))
+
+		wpAns := strings.ToLower(prompt("Install WordPress on first start? (y/n)", "y"))
 
 		cfg := &Config{
 			Name:   name,
@@ -52,8 +58,18 @@ var initCmd = &cobra.Command{
 		cfg.Services.Redis = redisAns == "y" || redisAns == "yes"
 		cfg.Services.Mailpit = mailpitAns == "y" || mailpitAns == "yes"
 		cfg.Services.Adminer = adminerAns == "y" || adminerAns == "yes"
+		cfg.Services.Cron = cronAns == "y" || cronAns == "yes"
 		cfg.TLS.Enabled = tlsAns == "y" || tlsAns == "yes"
-		cfg.Xdebug.Enabled = false
+		if wpAns == "y" || wpAns == "yes" {
+			cfg.WordPress = WordPressCfg{
+				Version:       prompt("WordPress version", "latest"),
+				Locale:        prompt("WordPress locale", "en_US"),
+				Title:         name,
+				AdminUser:     "admin",
+				AdminPassword: "admin",
+				AdminEmail:    "admin@" + domain,
+			}
+		}
 		cfg.Perf.Sync = "bind"
 		cfg.Perf.Excludes = []string{"node_modules", "vendor", ".git"}
     cfg.Database.Persist = persist
@@ -91,20 +107,19 @@ var initCmd = &cobra.Command{
 		}
 
 		// Write default templates if missing
-		writeIfMissing(filepath.Join(tplDir, "docker-compose.tmpl.yml"), []byte(dockerComposeTemplate))
-		writeIfMissing(filepath.Join(tplDir, "nginx.conf.tmpl"), []byte(nginxConfTemplate))
-		writeIfMissing(filepath.Join(tplDir, "php.Dockerfile.tmpl"), []byte(phpDockerfileTemplate))
-		writeIfMissing(filepath.Join(tplDir, "Caddyfile.mkcert.tmpl"), []byte(caddyfileMkcertTemplate))
-    writeIfMissing(filepath.Join(tplDir, "Caddyfile.http.tmpl"), []byte(caddyfileHttpTemplate))
+		for _, t := range builtinTemplates {
+			writeIfMissing(filepath.Join(tplDir, t.name), []byte(t.content))
+		}
 
 
 		// Bootstrap a simple index.php if docroot is empty
+		// (WordPress itself is downloaded on first start when configured)
 		if cfg.Web.Docroot == "" {
 			cfg.Web.Docroot = "."
 		}
+		_ = os.MkdirAll(cfg.Web.Docroot, 0o755)
 		indexPath := filepath.Join(cfg.Web.Docroot, "index.php")
-		if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-			_ = os.MkdirAll(cfg.Web.Docroot, 0o755)
+		if _, err := os.Stat(indexPath); os.IsNotExist(err) && cfg.WordPress.Version == "" {
 			_ = os.WriteFile(indexPath, []byte("<?php phpinfo();"), 0o644)
 			fmt.Printf("Wrote %s\n", indexPath)
 		}
@@ -137,6 +152,49 @@ func prompt(label, def string) string {
 	return text
 }
 
+func init() {
+	initCmd.Flags().Bool("refresh-templates", false, "only replace .wpdev/templates with this version's templates (keeps .bak copies)")
+}
+
+// builtinTemplates are written to .wpdev/templates by init.
+var builtinTemplates = []struct{ name, content string }{
+	{"docker-compose.tmpl.yml", dockerComposeTemplate},
+	{"nginx.conf.tmpl", nginxConfTemplate},
+	{"php.Dockerfile.tmpl", phpDockerfileTemplate},
+	{"Caddyfile.mkcert.tmpl", caddyfileMkcertTemplate},
+	{"Caddyfile.http.tmpl", caddyfileHttpTemplate},
+	{"media-cache.conf.tmpl", mediaCacheConfTemplate},
+}
+
+// refreshTemplates replaces the project's templates with the built-in ones
+// of this wpdev version, keeping a .bak of every file it changes.
+func refreshTemplates() error {
+	tplDir := filepath.Join(".wpdev", "templates")
+	if err := os.MkdirAll(tplDir, 0o755); err != nil { return err }
+	changed := 0
+	for _, t := range builtinTemplates {
+		path := filepath.Join(tplDir, t.name)
+		old, err := os.ReadFile(path)
+		if err == nil && string(old) == t.content {
+			continue
+		}
+		if err == nil {
+			if err := os.WriteFile(path+".bak", old, 0o644); err != nil { return err }
+			fmt.Printf("Updated %s (previous version in %s.bak)\n", path, t.name)
+		} else {
+			fmt.Println("Wrote", path)
+		}
+		if err := os.WriteFile(path, []byte(t.content), 0o644); err != nil { return err }
+		changed++
+	}
+	if changed == 0 {
+		fmt.Println("Templates are up to date.")
+		return nil
+	}
+	fmt.Println("Re-apply any customizations from the .bak files, then run `wpdev rebuild`.")
+	return nil
+}
+
 func writeIfMissing(path string, content []byte) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		_ = os.WriteFile(path, content, 0o644)
@@ -153,19 +211,35 @@ services:
       dockerfile: .wpdev/generated/php.Dockerfile
       args:
         PHP_VERSION: {{ .Web.PHP }}
+        WPDEV_UID: {{ .HostUID }}
+        WPDEV_GID: {{ .HostGID }}
+    image: {{ .PHPImage }}
     volumes:
       - ./:/var/www/html:delegated
+      - ./.wpdev/generated/zz-wpdev.ini:/usr/local/etc/php/conf.d/zz-wpdev.ini:ro
+{{- range .BindMounts }}
+      - {{ .Host }}:{{ .Target }}{{ if .ReadOnly }}:ro{{ end }}
+{{- end }}
     environment:
-      - XDEBUG_MODE={{ if .Xdebug.Enabled }}debug,develop{{ else }}off{{ end }}
-      - PHP_IDE_CONFIG=serverName=wpdev
+      - PHP_IDE_CONFIG=serverName={{ .IDEServerName }}
+{{- if .HostGateway }}
+    extra_hosts:
+      - host.docker.internal:host-gateway
+{{- end }}
     depends_on:
       - db
+{{- if .Services.Mailpit }}
+      - mailpit
+{{- end }}
 {{- if ne .Web.Server "apache" }}
 
   web:
     image: nginx:stable
     volumes:
       - ./:/var/www/html:delegated
+{{- range .BindMounts }}
+      - {{ .Host }}:{{ .Target }}:ro
+{{- end }}
       - ./.wpdev/generated/nginx.conf:/etc/nginx/conf.d/default.conf
     depends_on:
       - php
@@ -187,11 +261,57 @@ services:
     ports:
       - "{{ .Database.Portforward }}:3306"
 
+{{- if .Services.Redis }}
+
+  redis:
+    image: redis:7-alpine
+{{- end }}
+
 {{- if .Services.Mailpit }}
   mailpit:
     image: axllent/mailpit
 {{- end }}
 
+{{- if .Services.Cron }}
+
+  cron:
+    build:
+      context: .
+      dockerfile: .wpdev/generated/php.Dockerfile
+      args:
+        PHP_VERSION: {{ .Web.PHP }}
+        WPDEV_UID: {{ .HostUID }}
+        WPDEV_GID: {{ .HostGID }}
+    image: {{ .PHPImage }}
+    user: www-data
+    volumes:
+      - ./:/var/www/html:delegated
+      - ./.wpdev/generated/zz-wpdev.ini:/usr/local/etc/php/conf.d/zz-wpdev.ini:ro
+{{- range .BindMounts }}
+      - {{ .Host }}:{{ .Target }}{{ if .ReadOnly }}:ro{{ end }}
+{{- end }}
+    environment:
+      - HOME=/tmp
+      - XDEBUG_MODE=off
+    command:
+      - sh
+      - -c
+      - |
+        cd /var/www/html/{{ .Web.Docroot }}
+        while true; do
+          if wp core is-installed 2>/dev/null; then
+{{- if .WordPress.Multisite }}
+            for url in $$(wp site list --field=url); do wp cron event run --due-now --quiet --url="$$url"; done
+{{- else }}
+            wp cron event run --due-now --quiet
+{{- end }}
+          fi
+          sleep {{ or .Services.CronInterval 60 }}
+        done
+    depends_on:
+      - db
+{{- end }}
+
 {{- if .Services.Adminer }}
   adminer:
     image: adminer:latest
@@ -219,6 +339,19 @@ services:
     volumes:
       - ./.wpdev/generated/Caddyfile:/etc/caddy/Caddyfile:ro
       - ./.wpdev/certs:/certs:ro
+{{- if .MediaProxy }}
+      - ./:/srv:ro
+{{- end }}
+{{- if and .MediaProxy .MediaProxyCache }}
+
+  media-cache:
+    # Stores fetched files in the bind-mounted docroot, so it runs as the host user
+    image: nginxinc/nginx-unprivileged:stable
+    user: "{{ .HostUID }}:{{ .HostGID }}"
+    volumes:
+      - ./:/var/www/html
+      - ./.wpdev/generated/media-cache.conf:/etc/nginx/conf.d/default.conf:ro
+{{- end }}
 
 {{- if ne .Database.Persist "bind" }}
 volumes:
@@ -247,6 +380,16 @@ server {
 
   set $forwarded_proto $http_x_forwarded_proto;
   if ($forwarded_proto = "") { set $forwarded_proto $scheme; }
+{{- if eq .WordPress.Multisite "subdirectory" }}
+
+  # Multisite (subdirectory): /<site>/wp-* and /<site>/*.php are the core files
+  absolute_redirect off;
+  if (!-e $request_filename) {
+    rewrite ^(/[^/]+)?/wp-admin$ $1/wp-admin/ permanent;
+    rewrite ^(/[^/]+)?(/wp-.*) $2 last;
+    rewrite ^(/[^/]+)?(/.*\.php) $2 last;
+  }
+{{- end }}
 
 
   location / {
@@ -279,14 +422,62 @@ RUN set -eux; \
       ghostscript \
       libavif-dev libfreetype6-dev libicu-dev libjpeg-dev libpng-dev libwebp-dev \
       libzip-dev libmagickwand-dev libmagickcore-7.q16-10 libzip5 \
-      mariadb-client git unzip; \
+      mariadb-client git unzip{{ range .PHP.Packages }} {{ . }}{{ end }}; \
     rm -rf /var/lib/apt/lists/*
 
 # ── PHP extensions (WordPress recommendations) ──────────────────────────────────
 RUN set -eux; \
     docker-php-ext-configure gd --with-avif --with-freetype --with-jpeg --with-webp; \
-    docker-php-ext-install -j"$(nproc)" bcmath exif gd intl mysqli soap zip; \
+    docker-php-ext-install -j"$(nproc)" bcmath exif gd intl mysqli soap zip
+{{- if not (.PHP.HasPecl "imagick") }}; \
     pecl install imagick-3.8.0; docker-php-ext-enable imagick
+{{- end }}
+{{- if or .PHP.Extensions .PHP.Pecl }}
+
+# ── Project extensions (php.extensions / php.pecl) ─────────────────────────────
+RUN set -eux
+{{- if .PHP.Extensions }}; \
+    docker-php-ext-install -j"$(nproc)"{{ range .PHP.Extensions }} {{ . }}{{ end }}
+{{- end }}
+{{- range .PHP.PeclPackages }}; \
+    pecl install {{ .Install }}; docker-php-ext-enable {{ .Name }}
+{{- end }}; \
+    rm -rf /tmp/pear
+{{- end }}
+
+# ── Xdebug, off until wpdev xdebug <mode> sets it in zz-wpdev.ini ──────────────
+{{- if not (.PHP.HasPecl "xdebug") }}
+RUN set -eux; \
+    pecl install xdebug-{{ .XdebugRelease }}; docker-php-ext-enable xdebug; \
+    rm -rf /tmp/pear
+{{- end }}
+RUN echo 'xdebug.mode=off' > /usr/local/etc/php/conf.d/xdebug-wpdev.ini
+
+# ── WP-CLI (wpdev wp ...) ──────────────────────────────────────────────────────
+RUN set -eux; \
+    curl -fsSL -o /usr/local/bin/wp https://raw.githubusercontent.com/wp-cli/builds/gh-pages/phar/wp-cli.phar; \
+    chmod +x /usr/local/bin/wp
+
+# ── Composer (wpdev composer ...) ──────────────────────────────────────────────
+COPY --from=composer:2 /usr/bin/composer /usr/bin/composer
+
+# ── www-data = host user, so files PHP writes stay editable on the host ────────
+ARG WPDEV_UID=33
+ARG WPDEV_GID=33
+RUN set -eux; \
+    if [ "$(id -g www-data)" != "$WPDEV_GID" ]; then groupmod -o -g "$WPDEV_GID" www-data; fi; \
+    if [ "$(id -u www-data)" != "$WPDEV_UID" ]; then usermod -o -u "$WPDEV_UID" -g "$WPDEV_GID" www-data; fi; \
+    chown www-data:www-data /var/www
+{{- if .Services.Mailpit }}
+
+# ── mail() → Mailpit (wpdev mail ...) ──────────────────────────────────────────
+RUN set -eux; \
+    apt-get update; \
+    apt-get install -y --no-install-recommends msmtp; \
+    rm -rf /var/lib/apt/lists/*; \
+    printf 'account default\nhost mailpit\nport 1025\nfrom wordpress@{{ .Domain }}\n' > /etc/msmtprc; \
+    echo 'sendmail_path = "/usr/bin/msmtp -t"' > /usr/local/etc/php/conf.d/mailpit.ini
+{{- end }}
 
 # ── Opcache + sane dev logging ─────────────────────────────────────────────────
 RUN set -eux; \
@@ -337,6 +528,28 @@ RUN set -eux; \
 WORKDIR /var/www/html
 `
 
+// mediaCacheConfTemplate fetches missing uploads from the media_proxy origin
+// and stores them under the local docroot, so each file is fetched once.
+const mediaCacheConfTemplate = `
+server {
+  listen 8080;
+  server_name _;
+  root /var/www/html/{{ .Web.Docroot }};
+
+  location / {
+    try_files $uri @origin;
+  }
+
+  location @origin {
+    proxy_pass {{ .MediaProxy }};
+    proxy_ssl_server_name on;
+    proxy_store on;
+    proxy_store_access user:rw group:rw all:r;
+    proxy_temp_path /var/www/html/.wpdev/media-tmp;
+  }
+}
+`
+
 const caddyfileMkcertTemplate = `
 {{ $domain := .Domain }}
 {{ $up := "php:80" }}{{ if ne .Web.Server "apache" }}{{ $up = "web:80" }}{{ end }}
@@ -345,6 +558,23 @@ https://{{$domain}} {
   encode gzip
   log
   tls /certs/{{$domain}}.pem /certs/{{$domain}}-key.pem
+{{- if .MediaProxy }}
+  @remoteMedia {
+    path /wp-content/uploads/*
+    not file {
+      root /srv/{{ .Web.Docroot }}
+    }
+  }
+  handle @remoteMedia {
+{{- if .MediaProxyCache }}
+    reverse_proxy media-cache:8080
+{{- else }}
+    reverse_proxy {{ .MediaProxy }} {
+      header_up Host {upstream_hostport}
+    }
+{{- end }}
+  }
+{{- end }}
   reverse_proxy {{$up}} {
     header_up X-Forwarded-Proto https
     header_up X-Forwarded-Host {host}
@@ -354,6 +584,40 @@ https://{{$domain}} {
 http://{{$domain}} {
   redir https://{{$domain}}{uri} 308
 }
+{{- if eq .WordPress.Multisite "subdomain" }}
+
+# Multisite subdomains; more specific hosts (mail., db.) still win
+https://*.{{$domain}} {
+  encode gzip
+  log
+  tls /certs/_wildcard.{{$domain}}.pem /certs/_wildcard.{{$domain}}-key.pem
+  reverse_proxy {{$up}} {
+    header_up X-Forwarded-Proto https
+    header_up X-Forwarded-Host {host}
+    header_up X-Real-IP {remote_host}
+  }
+}
+http://*.{{$domain}} {
+  redir https://{host}{uri} 308
+}
+{{- end }}
+{{- range .WordPress.Domains }}
+
+# Mapped domain (wpdev site add)
+https://{{ . }} {
+  encode gzip
+  log
+  tls /certs/{{ . }}.pem /certs/{{ . }}-key.pem
+  reverse_proxy {{$up}} {
+    header_up X-Forwarded-Proto https
+    header_up X-Forwarded-Host {host}
+    header_up X-Real-IP {remote_host}
+  }
+}
+http://{{ . }} {
+  redir https://{{ . }}{uri} 308
+}
+{{- end }}
 
 {{ if .Services.Mailpit }}
 https://mail.{{$domain}} {
@@ -387,8 +651,43 @@ const caddyfileHttpTemplate = `
 http://{{$domain}} {
   encode gzip
   log
+{{- if .MediaProxy }}
+  @remoteMedia {
+    path /wp-content/uploads/*
+    not file {
+      root /srv/{{ .Web.Docroot }}
+    }
+  }
+  handle @remoteMedia {
+{{- if .MediaProxyCache }}
+    reverse_proxy media-cache:8080
+{{- else }}
+    reverse_proxy {{ .MediaProxy }} {
+      header_up Host {upstream_hostport}
+    }
+{{- end }}
+  }
+{{- end }}
+  reverse_proxy {{$up}}
+}
+{{- if eq .WordPress.Multisite "subdomain" }}
+
+# Multisite subdomains; more specific hosts (mail., db.) still win
+http://*.{{$domain}} {
+  encode gzip
+  log
   reverse_proxy {{$up}}
 }
+{{- end }}
+{{- range .WordPress.Domains }}
+
+# Mapped domain (wpdev site add)
+http://{{ . }} {
+  encode gzip
+  log
+  reverse_proxy {{$up}}
+}
+{{- end }}
 
 {{ if .Services.Mailpit }}
 http://mail.{{$domain}} {
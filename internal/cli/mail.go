@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var mailCmd = &cobra.Command{
+	Use:   "mail",
+	Short: "Read mail captured by Mailpit",
+}
+
+var mailListLimit int
+
+var mailListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List captured messages, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mp, err := newMailpit()
+		if err != nil { return err }
+		var res struct {
+			Total    int             `json:"total"`
+			Messages []mailpitSummary `json:"messages"`
+		}
+		if err := mp.do("GET", fmt.Sprintf("/api/v1/messages?limit=%d", mailListLimit), &res); err != nil { return err }
+		if len(res.Messages) == 0 {
+			fmt.Println("No messages.")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tRECEIVED\tFROM\tTO\tSUBJECT")
+		for _, m := range res.Messages {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.ID, m.Created.Local().Format("2006-01-02 15:04:05"),
+				m.From.Address, joinAddresses(m.To), m.Subject)
+		}
+		if err := tw.Flush(); err != nil { return err }
+		if res.Total > len(res.Messages) {
+			fmt.Printf("(%d of %d shown, use --limit)\n", len(res.Messages), res.Total)
+		}
+		return nil
+	},
+}
+
+var mailShowHTML bool
+
+var mailShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Print a message (default: the latest)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mp, err := newMailpit()
+		if err != nil { return err }
+		id := "latest"
+		if len(args) == 1 {
+			id = args[0]
+		}
+		var m struct {
+			mailpitSummary
+			Cc          []mailpitAddress `json:"Cc"`
+			Text        string           `json:"Text"`
+			HTML        string           `json:"HTML"`
+			Attachments []struct {
+				FileName string `json:"FileName"`
+				Size     int64  `json:"Size"`
+			} `json:"Attachments"`
+		}
+		if err := mp.do("GET", "/api/v1/message/"+id, &m); err != nil { return err }
+		fmt.Println("From:   ", m.From.Address)
+		fmt.Println("To:     ", joinAddresses(m.To))
+		if len(m.Cc) > 0 {
+			fmt.Println("Cc:     ", joinAddresses(m.Cc))
+		}
+		fmt.Println("Date:   ", m.Date.Local().Format(time.RFC1123Z))
+		fmt.Println("Subject:", m.Subject)
+		for _, a := range m.Attachments {
+			fmt.Printf("Attached: %s (%s)\n", a.FileName, humanBytes(a.Size))
+		}
+		fmt.Println()
+		body := m.Text
+		if mailShowHTML || body == "" {
+			body = m.HTML
+		}
+		fmt.Println(strings.TrimRight(body, "\n"))
+		return nil
+	},
+}
+
+var mailClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all captured messages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mp, err := newMailpit()
+		if err != nil { return err }
+		if err := mp.do("DELETE", "/api/v1/messages", nil); err != nil { return err }
+		fmt.Println("Mailbox cleared.")
+		return nil
+	},
+}
+
+func init() {
+	mailListCmd.Flags().IntVarP(&mailListLimit, "limit", "n", 50, "number of messages to show")
+	mailShowCmd.Flags().BoolVar(&mailShowHTML, "html", false, "print the HTML part instead of the text part")
+	mailCmd.AddCommand(mailListCmd, mailShowCmd, mailClearCmd)
+	rootCmd.AddCommand(mailCmd)
+}
+
+type mailpitAddress struct {
+	Name    string `json:"Name"`
+	Address string `json:"Address"`
+}
+
+type mailpitSummary struct {
+	ID      string           `json:"ID"`
+	From    mailpitAddress   `json:"From"`
+	To      []mailpitAddress `json:"To"`
+	Subject string           `json:"Subject"`
+	Created time.Time        `json:"Created"`
+	Date    time.Time        `json:"Date"`
+}
+
+func joinAddresses(as []mailpitAddress) string {
+	var out []string
+	for _, a := range as {
+		out = append(out, a.Address)
+	}
+	return strings.Join(out, ", ")
+}
+
+// mailpit talks to the Mailpit API through Caddy's mail.<domain> route.
+type mailpit struct {
+	base   string
+	client *http.Client
+}
+
+func newMailpit() (*mailpit, error) {
+	cfg, err := loadConfig(".wpdev.yml")
+	if err != nil { return nil, err }
+	if !cfg.Services.Mailpit {
+		return nil, fmt.Errorf("mailpit is not enabled (services.mailpit in .wpdev.yml)")
+	}
+	return &mailpit{
+		base:   strings.Replace(siteURL(cfg), "://", "://mail.", 1),
+		client: localHTTPClient(cfg, 15*time.Second),
+	}, nil
+}
+
+// localHTTPClient reaches the stack's Caddy routes by name while always
+// dialing this machine, so it works without DNS for the domain.
+func localHTTPClient(cfg *Config, timeout time.Duration) *http.Client {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, _ := net.SplitHostPort(addr)
+			return (&net.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, network, net.JoinHostPort("127.0.0.1", port))
+		},
+	}
+	if cfg.TLS.Enabled {
+		tr.TLSClientConfig = &tls.Config{RootCAs: mkcertRoots()}
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}
+}
+
+func (m *mailpit) do(method, path string, out any) error {
+	req, err := http.NewRequest(method, m.base+path, nil)
+	if err != nil { return err }
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mailpit: %w (is the stack running?)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("mailpit: no such message")
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("mailpit: %s %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// mkcertRoots adds mkcert's CA to the system pool, in case `mkcert -install`
+// could not add it to the system store.
+func mkcertRoots() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	out, err := exec.Command("mkcert", "-CAROOT").Output()
+	if err != nil {
+		return pool
+	}
+	if pem, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "rootCA.pem")); err == nil {
+		pool.AppendCertsFromPEM(pem)
+	}
+	return pool
+}
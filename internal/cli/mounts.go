@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BindMount is an extra volume on the php and web services, rendered by the
+// compose template.
+type BindMount struct {
+	Host     string // as compose expects it: ./rel, ../rel or absolute
+	Target   string // absolute path inside the containers
+	ReadOnly bool
+}
+
+// BindMounts returns the mounts: entries followed by local plugin and theme
+// directories, sorted by target.
+func (c *Config) BindMounts() []BindMount {
+	var out []BindMount
+	for _, m := range c.Mounts {
+		out = append(out, BindMount{
+			Host:     composeHostPath(m.Host),
+			Target:   path.Join(wpPath(c), path.Clean("/"+filepath.ToSlash(m.Path))),
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	out = append(out, packageMounts(c)...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+	return out
+}
+
+// composeHostPath makes a host path usable as a compose bind source: ~ is
+// expanded and relative paths get the ./ compose requires.
+func composeHostPath(p string) string {
+	p = expandHome(p)
+	if filepath.IsAbs(p) {
+		return filepath.ToSlash(filepath.Clean(p))
+	}
+	rel := filepath.ToSlash(filepath.Clean(p))
+	if strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
+
+// checkMounts validates mounts: and local packages before compose runs, as
+// compose would otherwise create missing sources as empty root-owned dirs.
+func checkMounts(cfg *Config) error {
+	for _, m := range cfg.Mounts {
+		if m.Host == "" || m.Path == "" {
+			return fmt.Errorf("mounts: host and path are required (got host %q, path %q)", m.Host, m.Path)
+		}
+		if filepath.IsAbs(m.Path) || strings.HasPrefix(path.Clean(filepath.ToSlash(m.Path)), "..") {
+			return fmt.Errorf("mounts: path %q must be relative to the docroot", m.Path)
+		}
+		if _, err := os.Stat(expandHome(m.Host)); err != nil {
+			return fmt.Errorf("mounts: %w", err)
+		}
+	}
+	if err := checkPackageSources(cfg); err != nil { return err }
+
+	seen := map[string]string{}
+	for _, m := range cfg.BindMounts() {
+		if other, ok := seen[m.Target]; ok {
+			return fmt.Errorf("mounts: %s and %s both mount to %s", other, m.Host, m.Target)
+		}
+		seen[m.Target] = m.Host
+	}
+	return nil
+}
+
+// pathMapping pairs a path in the php container with the host path the IDE
+// sees, for Xdebug.
+type pathMapping struct {
+	Container string
+	Host      string
+}
+
+// xdebugPathMappings lists the project root and every extra mount, most
+// specific first, so breakpoints in mounted code resolve.
+func xdebugPathMappings(cfg *Config) ([]pathMapping, error) {
+	wd, err := os.Getwd()
+	if err != nil { return nil, err }
+	out := []pathMapping{{Container: "/var/www/html", Host: wd}}
+	for _, m := range cfg.BindMounts() {
+		host := filepath.FromSlash(m.Host)
+		if !filepath.IsAbs(host) {
+			host = filepath.Join(wd, host)
+		}
+		out = append(out, pathMapping{Container: m.Target, Host: host})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Container) > len(out[j].Container) })
+	return out, nil
+}
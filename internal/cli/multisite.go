@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var siteCmd = &cobra.Command{
+	Use:   "site",
+	Short: "Multisite network helpers",
+}
+
+var siteTitle string
+
+var siteAddCmd = &cobra.Command{
+	Use:   "add <slug|domain>",
+	Short: "Create a subsite; a domain outside the network is mapped and gets certs and DNS",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		network, err := networkInstalled(cfg)
+		if err != nil { return err }
+		if !network {
+			return fmt.Errorf("not a multisite network: set wordpress.multisite (subdomain|subdirectory) and run `wpdev start`")
+		}
+		arg := strings.ToLower(strings.TrimSpace(args[0]))
+		if !validHost.MatchString(arg) {
+			return fmt.Errorf("%q is not a valid slug or domain", args[0])
+		}
+
+		slug, domain := arg, ""
+		if strings.Contains(arg, ".") {
+			sub, ok := strings.CutSuffix(arg, "."+cfg.Domain)
+			if ok && cfg.WordPress.Multisite == "subdomain" && !strings.Contains(sub, ".") {
+				slug = sub
+			} else {
+				slug, domain = strings.ReplaceAll(arg, ".", "-"), arg
+			}
+		}
+
+		c := wpExec(cfg, false, "site", "create", "--slug="+slug, "--title="+orDefault(siteTitle, slug), "--porcelain")
+		c.Stderr = os.Stderr
+		out, err := c.Output()
+		if err != nil { return err }
+		id := strings.TrimSpace(string(out))
+
+		if domain == "" {
+			url := siteURL(cfg) + "/" + slug + "/"
+			if cfg.WordPress.Multisite == "subdomain" {
+				host := slug + "." + cfg.Domain
+				if err := registerHost(host); err != nil { return err }
+				url = strings.Replace(siteURL(cfg), "://", "://"+slug+".", 1) + "/"
+			}
+			fmt.Printf("Site %s created: %s\n", id, url)
+			return nil
+		}
+
+		// Map the new site onto its own domain (core supports this since 4.5).
+		url := strings.Replace(siteURL(cfg), cfg.Domain, domain, 1)
+		php := fmt.Sprintf(`update_blog_details(%s, array('domain' => '%s', 'path' => '/')); switch_to_blog(%s); update_option('home', '%s'); update_option('siteurl', '%s');`,
+			id, domain, id, url, url)
+		c = wpExec(cfg, false, "eval", php)
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		if err := c.Run(); err != nil { return err }
+
+		if !slices.Contains(cfg.WordPress.Domains, domain) {
+			cfg.WordPress.Domains = append(cfg.WordPress.Domains, domain)
+			if err := saveConfig(".wpdev.yml", cfg); err != nil { return err }
+		}
+		if cfg.TLS.Enabled && !certsPresent(domain) {
+			if err := generateCertsForDomain(domain); err != nil { return err }
+		}
+		if err := registerHost(domain); err != nil { return err }
+		if err := renderTemplates(cfg); err != nil { return err }
+		if err := composeRun("exec", "caddy", "caddy", "reload", "--config", "/etc/caddy/Caddyfile"); err != nil { return err }
+		fmt.Printf("Site %s created: %s/\n", id, url)
+		return nil
+	},
+}
+
+func init() {
+	siteAddCmd.Flags().StringVar(&siteTitle, "title", "", "site title (default: the slug)")
+	siteCmd.AddCommand(siteAddCmd)
+	rootCmd.AddCommand(siteCmd)
+}
+
+// validHost also keeps values safe to embed in the PHP passed to wp eval.
+var validHost = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// networkInstalled reports whether the database holds the network tables,
+// so the network constants are only rendered once they exist. The database
+// is the only record: an import, a teammate's dump or a `db branch` switch
+// can add or drop them at any time.
+func networkInstalled(cfg *Config) (bool, error) {
+	if cfg.WordPress.Multisite == "" {
+		return false, nil
+	}
+	if err := waitForDB(cfg); err != nil { return false, err }
+	_, rows, err := dbQuery(cfg, "SHOW TABLES LIKE '"+likeEscape(tablePrefix(cfg)+"sitemeta")+"'")
+	if err != nil { return false, err }
+	return len(rows) > 0, nil
+}
+
+// likeEscape makes a table name match literally in a LIKE pattern; the usual
+// wp_ prefix would otherwise match any character in place of the underscore.
+func likeEscape(table string) string {
+	return strings.ReplaceAll(table, "_", `\_`)
+}
+
+// ensureNetwork converts the installed single site into the configured
+// network once, then renders the network constants. A database that already
+// has the network tables (an import, a teammate's dump) is left as it is.
+func ensureNetwork(cfg *Config) error {
+	network, err := networkInstalled(cfg)
+	if err != nil || network || cfg.WordPress.Multisite == "" { return err }
+	fmt.Println("Converting to a multisite network...")
+	args := []string{"core", "multisite-convert", "--skip-config", "--title=" + wpTitle(cfg)}
+	if cfg.WordPress.Multisite == "subdomain" {
+		args = append(args, "--subdomains")
+	}
+	c := wpExec(cfg, false, args...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	if err := c.Run(); err != nil { return err }
+	return syncWPConfig(cfg)
+}
+
+// htaccessSubdirectory is the rule set WordPress documents for subdirectory
+// networks on Apache; it replaces the single-site block.
+const htaccessSubdirectory = `# BEGIN WordPress
+RewriteEngine On
+RewriteRule .* - [E=HTTP_AUTHORIZATION:%{HTTP:Authorization}]
+RewriteBase /
+RewriteRule ^index\.php$ - [L]
+
+# add a trailing slash to /wp-admin
+RewriteRule ^([_0-9a-zA-Z-]+/)?wp-admin$ $1wp-admin/ [R=301,L]
+
+RewriteCond %{REQUEST_FILENAME} -f [OR]
+RewriteCond %{REQUEST_FILENAME} -d
+RewriteRule ^ - [L]
+RewriteRule ^([_0-9a-zA-Z-]+/)?(wp-(content|admin|includes).*) $2 [L]
+RewriteRule ^([_0-9a-zA-Z-]+/)?(.*\.php)$ $2 [L]
+RewriteRule . index.php [L]
+# END WordPress
+`
+
+// syncHtaccess writes the multisite rules between the "# BEGIN WordPress"
+// and "# END WordPress" markers, leaving anything else in the file alone.
+func syncHtaccess(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) { return err }
+	src := string(b)
+	out := src
+	start := strings.Index(src, "# BEGIN WordPress")
+	end := strings.Index(src, "# END WordPress")
+	switch {
+	case start >= 0 && end > start:
+		end += len("# END WordPress")
+		if end < len(src) && src[end] == '\n' {
+			end++
+		}
+		out = src[:start] + htaccessSubdirectory + src[end:]
+	case strings.TrimSpace(src) == "":
+		out = htaccessSubdirectory
+	default:
+		out = strings.TrimRight(src, "\n") + "\n\n" + htaccessSubdirectory
+	}
+	if out == src {
+		return nil
+	}
+	return os.WriteFile(path, []byte(out), 0o644)
+}
+
+// registerHost makes host resolve to this machine. Nothing is done when it
+// already does (e.g. dnsmasq for *.test); otherwise it is appended to the
+// hosts file, or the command to do that is printed when it needs root.
+func registerHost(host string) error {
+	if addrs, err := net.LookupHost(host); err == nil {
+		for _, a := range addrs {
+			if ip := net.ParseIP(a); ip != nil && ip.IsLoopback() {
+				return nil
+			}
+		}
+	}
+	hosts := "/etc/hosts"
+	if runtime.GOOS == "windows" {
+		hosts = filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	line := "127.0.0.1 " + host + " # wpdev"
+	f, err := os.OpenFile(hosts, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		fmt.Printf("%s does not resolve yet; add it to %s:\n  echo '%s' | sudo tee -a %s\n", host, hosts, line, hosts)
+		return nil
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, line); err != nil { return err }
+	fmt.Println("Added", host, "to", hosts)
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTablePrefix(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{}
+	cfg.Web.Docroot = dir
+	if got := tablePrefix(cfg); got != "wp_" {
+		t.Errorf("no wp-config.php: got %q, want wp_", got)
+	}
+
+	for src, want := range map[string]string{
+		"<?php\n$table_prefix = 'wp_';\n":            "wp_",
+		"<?php\n$table_prefix  = \"shop_2_\";\n":     "shop_2_",
+		"<?php\n// $table_prefix = 'old_';\n":        "wp_",
+		"<?php\n$table_prefix = 'x\\'; DROP';\n":     "wp_",
+		"<?php\n\t$table_prefix='Site1_'; // main\n": "Site1_",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "wp-config.php"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := tablePrefix(cfg); got != want {
+			t.Errorf("tablePrefix(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestLikeEscape(t *testing.T) {
+	if got, want := likeEscape("wp_2_sitemeta"), `wp\_2\_sitemeta`; got != want {
+		t.Errorf("likeEscape = %q, want %q", got, want)
+	}
+}
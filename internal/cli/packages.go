@@ -0,0 +1,319 @@
+package cli
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var wpSyncCheck bool
+
+var wpSyncCmd = &cobra.Command{
+	Use:   "wp-sync",
+	Short: "Install, activate and pin the plugins and themes listed in .wpdev.yml",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		if err := checkPackageSources(cfg); err != nil { return err }
+		drift, err := syncPackages(cfg, wpSyncCheck)
+		if err != nil { return err }
+		if !wpSyncCheck {
+			return nil
+		}
+		if len(drift) == 0 {
+			fmt.Println("Plugins and themes match .wpdev.yml")
+			return nil
+		}
+		for _, d := range drift {
+			fmt.Println(d)
+		}
+		return fmt.Errorf("%d difference(s) from .wpdev.yml; run `wpdev wp-sync` to fix", len(drift))
+	},
+}
+
+func init() {
+	wpSyncCmd.Flags().BoolVar(&wpSyncCheck, "check", false, "only report drift, exit non-zero if there is any")
+	rootCmd.AddCommand(wpSyncCmd)
+}
+
+// wantedPackage is a PackageSpec resolved against the host: the name
+// WordPress knows it by and how to install it.
+type wantedPackage struct {
+	typ     string // plugin|theme
+	name    string
+	version string // "" = any
+	active  bool
+	pinned  bool // active was set explicitly
+	install string // argument for `wp <typ> install`; "" for mounted dirs
+}
+
+type installedPackage struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// checkPackageSources makes sure local zips and directories exist before
+// compose would create missing bind-mount sources as empty root-owned dirs.
+func checkPackageSources(cfg *Config) error {
+	for _, list := range [][]PackageSpec{cfg.WordPress.Plugins, cfg.WordPress.Themes} {
+		for _, p := range list {
+			if p.kind() == "slug" {
+				continue
+			}
+			st, err := os.Stat(p.Source)
+			if err != nil {
+				return fmt.Errorf("wordpress package %s: %w", p.Source, err)
+			}
+			if (p.kind() == "dir") != st.IsDir() {
+				return fmt.Errorf("wordpress package %s: expected a directory or a .zip file", p.Source)
+			}
+		}
+	}
+	return nil
+}
+
+// packageMounts mounts local plugin and theme directories into wp-content.
+func packageMounts(c *Config) []BindMount {
+	var out []BindMount
+	for typ, list := range map[string][]PackageSpec{"plugins": c.WordPress.Plugins, "themes": c.WordPress.Themes} {
+		for _, p := range list {
+			if p.kind() != "dir" {
+				continue
+			}
+			out = append(out, BindMount{
+				Host:   composeHostPath(p.Source),
+				Target: path.Join(wpPath(c), "wp-content", typ, filepath.Base(filepath.Clean(p.Source))),
+			})
+		}
+	}
+	return out
+}
+
+// syncPackages brings installed plugins and themes in line with .wpdev.yml.
+// Nothing that is not listed is touched. With check it only returns the
+// differences.
+func syncPackages(cfg *Config, check bool) ([]string, error) {
+	var wanted []wantedPackage
+	for _, p := range cfg.WordPress.Plugins {
+		w, err := resolvePackage(cfg, "plugin", p)
+		if err != nil { return nil, err }
+		wanted = append(wanted, w)
+	}
+	for _, p := range cfg.WordPress.Themes {
+		w, err := resolvePackage(cfg, "theme", p)
+		if err != nil { return nil, err }
+		wanted = append(wanted, w)
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	if wpExec(cfg, false, "core", "is-installed").Run() != nil {
+		if check {
+			return nil, fmt.Errorf("WordPress is not installed (or the stack is not running)")
+		}
+		fmt.Println("WordPress is not installed yet; skipping plugins and themes")
+		return nil, nil
+	}
+
+	installed := map[string]map[string]installedPackage{}
+	for _, typ := range []string{"plugin", "theme"} {
+		m, err := installedPackages(cfg, typ)
+		if err != nil { return nil, err }
+		installed[typ] = m
+	}
+
+	var drift []string
+	for _, w := range wanted {
+		have, ok := installed[w.typ][w.name]
+		isActive := strings.HasPrefix(have.Status, "active")
+		var steps [][]string
+		switch {
+		case !ok && w.install == "":
+			drift = append(drift, fmt.Sprintf("%s %s: missing (mounted directory not visible; run `wpdev rebuild`)", w.typ, w.name))
+			continue
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s %s: not installed", w.typ, w.name))
+			steps = append(steps, installArgs(w, false))
+		case w.version != "" && have.Version != w.version && w.install != "":
+			drift = append(drift, fmt.Sprintf("%s %s: %s installed, want %s", w.typ, w.name, have.Version, w.version))
+			steps = append(steps, installArgs(w, true))
+		}
+		switch {
+		case w.active && !isActive:
+			if ok {
+				drift = append(drift, fmt.Sprintf("%s %s: inactive", w.typ, w.name))
+			}
+			steps = append(steps, activateArgs(cfg, w, "activate"))
+		case !w.active && w.pinned && isActive:
+			drift = append(drift, fmt.Sprintf("%s %s: active, want inactive", w.typ, w.name))
+			if w.typ == "plugin" {
+				steps = append(steps, activateArgs(cfg, w, "deactivate"))
+			}
+		}
+		if check {
+			continue
+		}
+		for _, args := range steps {
+			c := wpExec(cfg, false, args...)
+			c.Stdout, c.Stderr = os.Stdout, os.Stderr
+			if err := c.Run(); err != nil {
+				return nil, fmt.Errorf("wp %s: %w", strings.Join(args, " "), err)
+			}
+		}
+	}
+	return drift, nil
+}
+
+func installArgs(w wantedPackage, force bool) []string {
+	args := []string{w.typ, "install", w.install}
+	if w.version != "" && !strings.HasPrefix(w.install, "/") {
+		args = append(args, "--version="+w.version)
+	}
+	if force {
+		args = append(args, "--force")
+	}
+	return args
+}
+
+func activateArgs(cfg *Config, w wantedPackage, verb string) []string {
+	args := []string{w.typ, verb, w.name}
+	if w.typ == "plugin" && cfg.WordPress.Multisite != "" {
+		args = append(args, "--network")
+	}
+	return args
+}
+
+func installedPackages(cfg *Config, typ string) (map[string]installedPackage, error) {
+	c := wpExec(cfg, false, typ, "list", "--format=json", "--fields=name,status,version")
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("wp %s list: %w", typ, err)
+	}
+	var list []installedPackage
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("wp %s list: %w", typ, err)
+	}
+	m := map[string]installedPackage{}
+	for _, p := range list {
+		m[p.Name] = p
+	}
+	return m, nil
+}
+
+// resolvePackage works out name and version. Zips and directories are read
+// on the host, so their version comes from the plugin or theme header.
+func resolvePackage(cfg *Config, typ string, p PackageSpec) (wantedPackage, error) {
+	w := wantedPackage{typ: typ, active: typ == "plugin", version: p.Version}
+	if p.Active != nil {
+		w.active, w.pinned = *p.Active, true
+	}
+	if w.version == "latest" {
+		w.version = ""
+	}
+
+	switch p.kind() {
+	case "slug":
+		w.name, w.install = p.Source, p.Source
+	case "dir":
+		w.name, w.version = filepath.Base(filepath.Clean(p.Source)), ""
+	case "zip":
+		zr, err := zip.OpenReader(p.Source)
+		if err != nil { return w, fmt.Errorf("%s: %w", p.Source, err) }
+		defer zr.Close()
+		w.name, err = zipTopDir(&zr.Reader)
+		if err != nil { return w, fmt.Errorf("%s: %w", p.Source, err) }
+		w.version, err = packageVersion(zr, w.name, typ)
+		if err != nil { return w, fmt.Errorf("%s: %w", p.Source, err) }
+		w.install, err = containerFile(cfg, p.Source)
+		if err != nil { return w, err }
+	}
+	return w, nil
+}
+
+// zipTopDir returns the single directory a plugin or theme zip unpacks to.
+func zipTopDir(zr *zip.Reader) (string, error) {
+	top := ""
+	for _, f := range zr.File {
+		dir, _, _ := strings.Cut(f.Name, "/")
+		if strings.HasPrefix(dir, "__MACOSX") {
+			continue
+		}
+		if top != "" && dir != top {
+			return "", fmt.Errorf("expected a single top-level directory, found %s and %s", top, dir)
+		}
+		top = dir
+	}
+	if top == "" {
+		return "", fmt.Errorf("empty archive")
+	}
+	return top, nil
+}
+
+// headerVersion matches the "Version:" line the way WordPress' get_file_data does.
+var headerVersion = regexp.MustCompile(`(?mi)^(?:[ \t]*<\?php)?[ \t/*#@]*Version:(.*)$`)
+
+// packageVersion reads the header of style.css (themes) or of the main
+// plugin file, i.e. the top-level .php file with a "Plugin Name:" header.
+func packageVersion(fsys fs.FS, dir, typ string) (string, error) {
+	files := []string{path.Join(dir, "style.css")}
+	if typ == "plugin" {
+		files, _ = fs.Glob(fsys, path.Join(dir, "*.php"))
+	}
+	for _, name := range files {
+		f, err := fsys.Open(name)
+		if err != nil { continue }
+		head := make([]byte, 8192)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		head = head[:n]
+		if typ == "plugin" && !strings.Contains(string(head), "Plugin Name:") {
+			continue
+		}
+		if m := headerVersion.FindSubmatch(head); m != nil {
+			return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(string(m[1])), "*/")), nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("no %s header found in %s", typ, dir)
+}
+
+// containerFile maps a host file to a path the php container can read. Files
+// outside the project are copied into .wpdev/packages first.
+func containerFile(cfg *Config, src string) (string, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil { return "", err }
+	wd, err := os.Getwd()
+	if err != nil { return "", err }
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Join(".wpdev", "packages", filepath.Base(abs))
+		if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil { return "", err }
+		if err := copyFile(abs, rel); err != nil { return "", err }
+	}
+	return path.Join("/var/www/html", filepath.ToSlash(rel)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil { return err }
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil { return err }
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
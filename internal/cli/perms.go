@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var fixPermissionsCmd = &cobra.Command{
+	Use:   "fix-permissions",
+	Short: "Give files the containers created as root or www-data back to your user",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if runtime.GOOS == "windows" {
+			fmt.Println("Not needed on Windows: Docker Desktop does not map file owners.")
+			return nil
+		}
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		owner := fmt.Sprintf("%d:%d", cfg.HostUID(), cfg.HostGID())
+
+		// The database files belong to the db container's mysql user, and
+		// read-only mounts cannot be changed.
+		var skip []string
+		if cfg.Database.Persist == "bind" && cfg.Database.DataPath != "" {
+			skip = append(skip, path.Join("/var/www/html", cfg.Database.DataPath))
+		}
+		for _, m := range cfg.BindMounts() {
+			if m.ReadOnly {
+				skip = append(skip, m.Target)
+			}
+		}
+
+		find := []string{"find", "/var/www/html"}
+		if len(skip) > 0 {
+			find = append(find, "(")
+			for i, p := range skip {
+				if i > 0 {
+					find = append(find, "-o")
+				}
+				find = append(find, "-path", p)
+			}
+			find = append(find, ")", "-prune", "-o")
+		}
+		find = append(find, "(", "!", "-user", fmt.Sprint(cfg.HostUID()), "-o", "!", "-group", fmt.Sprint(cfg.HostGID()), ")",
+			"-exec", "chown", "-h", owner, "{}", "+")
+
+		fmt.Println("Changing owner to", owner, "where needed...")
+		c := phpExecAs("root", false, find...)
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		return c.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixPermissionsCmd)
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// phpImageRepo holds the PHP images of all projects. Tags are the PHP version
+// plus a hash of the rendered Dockerfile, so projects with the same setup
+// share one image and switching back to a version needs no rebuild.
+const phpImageRepo = "wpdev-php"
+
+var phpCmd = &cobra.Command{
+	Use:   "php",
+	Short: "PHP version and image helpers",
+}
+
+var phpVersionArg = regexp.MustCompile(`^\d+\.\d+$`)
+
+var phpSwitchCmd = &cobra.Command{
+	Use:   "switch <version>",
+	Short: "Switch PHP version, reusing a cached image when there is one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !phpVersionArg.MatchString(args[0]) {
+			return fmt.Errorf("expected a PHP version like 8.3, got %q", args[0])
+		}
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		previous := cfg.Web.PHP
+		cfg.Web.PHP = args[0]
+		if err := renderTemplates(cfg); err != nil { return err }
+		// Older compose templates build without an image tag and keep the old image
+		if img, want := loadRenderedCompose().Services["php"].Image, cfg.PHPImage(); img != want {
+			cfg.Web.PHP = previous
+			_ = renderTemplates(cfg)
+			return fmt.Errorf("docker-compose.yml runs php from %q instead of %s, so switching would keep the old PHP; "+
+				"refresh the templates with `wpdev init --refresh-templates`", img, want)
+		}
+		if err := saveConfig(".wpdev.yml", cfg); err != nil { return err }
+		if _, err := syncPHPIni(cfg); err != nil { return err }
+
+		if imageExists(cfg.PHPImage()) {
+			fmt.Println("Using cached image", cfg.PHPImage())
+		} else {
+			fmt.Println("No cached image for this setup; building", cfg.PHPImage())
+		}
+		// compose builds only when the tagged image is missing
+		services := []string{"up", "-d", "php"}
+		if cfg.Services.Cron {
+			services = append(services, "cron")
+		}
+		if err := composeRun(services...); err != nil { return err }
+		fmt.Println("PHP is now", cfg.Web.PHP)
+		return nil
+	},
+}
+
+var phpVersionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "List cached PHP images (shared by all projects) and their size",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := exec.Command("docker", "image", "ls", phpImageRepo, "--format", "{{json .}}").Output()
+		if err != nil { return fmt.Errorf("docker image ls: %w", err) }
+		current := currentPHPImage()
+
+		type image struct {
+			Tag          string `json:"Tag"`
+			Size         string `json:"Size"`
+			CreatedSince string `json:"CreatedSince"`
+		}
+		var images []image
+		sc := bufio.NewScanner(bytes.NewReader(out))
+		for sc.Scan() {
+			var im image
+			if err := json.Unmarshal(sc.Bytes(), &im); err != nil { return err }
+			images = append(images, im)
+		}
+		if len(images) == 0 {
+			fmt.Println("No cached PHP images yet.")
+			return nil
+		}
+		sort.Slice(images, func(i, j int) bool { return images[i].Tag > images[j].Tag })
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "\tPHP\tIMAGE\tSIZE\tCREATED")
+		for _, im := range images {
+			mark := ""
+			if phpImageRepo+":"+im.Tag == current {
+				mark = "*"
+			}
+			version, _, _ := strings.Cut(im.Tag, "-")
+			fmt.Fprintf(tw, "%s\t%s\t%s:%s\t%s\t%s\n", mark, version, phpImageRepo, im.Tag, im.Size, im.CreatedSince)
+		}
+		if err := tw.Flush(); err != nil { return err }
+		fmt.Println("* used by this project. Sizes include layers shared between images.")
+		return nil
+	},
+}
+
+func init() {
+	phpCmd.AddCommand(phpSwitchCmd, phpVersionsCmd)
+	rootCmd.AddCommand(phpCmd)
+}
+
+// phpImageTag hashes everything the image depends on: the Dockerfile and the
+// build args (PHP version, host UID/GID).
+func phpImageTag(cfg *Config, dockerfile []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %d:%d\n", cfg.Web.PHP, cfg.HostUID(), cfg.HostGID())
+	h.Write(dockerfile)
+	return phpImageRepo + ":" + cfg.Web.PHP + "-" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// HostUID is the UID www-data gets in the PHP image. Root and platforms
+// without UIDs (Windows) keep the image default.
+func (c *Config) HostUID() int {
+	if uid := os.Getuid(); uid > 0 {
+		return uid
+	}
+	return 33
+}
+
+func (c *Config) HostGID() int {
+	if gid := os.Getgid(); gid > 0 && os.Getuid() > 0 {
+		return gid
+	}
+	return 33
+}
+
+// PHPImage is the tag of the php service's image, for the compose template.
+func (c *Config) PHPImage() string {
+	if c.phpImage == "" {
+		return phpImageRepo + ":" + c.Web.PHP
+	}
+	return c.phpImage
+}
+
+// currentPHPImage is the tag this project last rendered, or "" outside a project.
+func currentPHPImage() string {
+	cfg, err := loadConfig(".wpdev.yml")
+	if err != nil { return "" }
+	b, err := os.ReadFile(filepath.Join(".wpdev", "generated", "php.Dockerfile"))
+	if err != nil { return "" }
+	return phpImageTag(cfg, b)
+}
+
+func imageExists(ref string) bool {
+	return exec.Command("docker", "image", "inspect", ref).Run() == nil
+}
+
+// phpIniPath is bind-mounted into conf.d of the php and cron containers. It
+// sorts last there, so it overrides the image's defaults.
+var phpIniPath = filepath.Join(".wpdev", "generated", "zz-wpdev.ini")
+
+const phpIniTarget = "/usr/local/etc/php/conf.d/zz-wpdev.ini"
+
+// syncPHPIni writes zz-wpdev.ini from php.ini and the Xdebug mode and reports
+// whether an existing file changed, i.e. whether running PHP needs a reload.
+func syncPHPIni(cfg *Config) (bool, error) {
+	if err := checkPHPIniMount(cfg); err != nil { return false, err }
+	var b strings.Builder
+	b.WriteString("; Generated by wpdev from php.ini and xdebug in .wpdev.yml; edits are overwritten.\n")
+	b.WriteString("\n; Xdebug (wpdev xdebug <mode>)\n")
+	fmt.Fprintf(&b, "xdebug.mode = %s\n", cfg.XdebugMode())
+	if cfg.XdebugMode() != "off" {
+		fmt.Fprintf(&b, "xdebug.start_with_request = %s\n", orDefault(cfg.Xdebug.StartWithRequest, "default"))
+		fmt.Fprintf(&b, "xdebug.output_dir = %s\n", xdebugOutputDir)
+		fmt.Fprintf(&b, "xdebug.client_host = %s\n", cfg.XdebugClientHost())
+		fmt.Fprintf(&b, "xdebug.client_port = %d\n", cfg.XdebugClientPort())
+		b.WriteString("xdebug.profiler_output_name = cachegrind.out.%u.%r\n") // unique, see wpdev profile
+		if err := os.MkdirAll(filepath.Join(".wpdev", "xdebug"), 0o755); err != nil { return false, err }
+		b.WriteString("xdebug.log_level = 0\n")
+	}
+	if len(cfg.PHP.Ini) > 0 {
+		b.WriteString("\n; php.ini\n")
+	}
+	keys := make([]string, 0, len(cfg.PHP.Ini))
+	for k := range cfg.PHP.Ini {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", k, cfg.PHP.Ini[k])
+	}
+
+	old, err := os.ReadFile(phpIniPath)
+	existed := err == nil
+	if existed && string(old) == b.String() {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(phpIniPath), 0o755); err != nil { return false, err }
+	if err := os.WriteFile(phpIniPath, []byte(b.String()), 0o644); err != nil { return false, err }
+	return existed, nil
+}
+
+// checkPHPIniMount fails when the rendered compose file comes from an older
+// template where zz-wpdev.ini would never reach PHP: without its mount, or
+// with an XDEBUG_MODE variable, which overrides xdebug.mode.
+func checkPHPIniMount(cfg *Config) error {
+	php, ok := loadRenderedCompose().Services["php"]
+	if !ok {
+		return nil
+	}
+	mode := cfg.XdebugMode()
+	envMode, hasEnv := php.env("XDEBUG_MODE")
+	if hasEnv && envMode != mode {
+		return fmt.Errorf("docker-compose.yml sets XDEBUG_MODE=%s, which overrides Xdebug mode %s; "+
+			"refresh the templates with `wpdev init --refresh-templates`", envMode, mode)
+	}
+	// Old templates set the mode through XDEBUG_MODE, which is fine while it agrees
+	if php.mounts(phpIniTarget) || (len(cfg.PHP.Ini) == 0 && (hasEnv || mode == "off")) {
+		return nil
+	}
+	return fmt.Errorf("docker-compose.yml does not mount %s at %s, so PHP would not see php.ini or Xdebug settings; "+
+		"refresh the templates with `wpdev init --refresh-templates`", phpIniPath, phpIniTarget)
+}
+
+// restartPHP applies ini changes without rebuilding the image.
+func restartPHP(cfg *Config) error {
+	fmt.Println("PHP settings changed — restarting PHP...")
+	services := []string{"restart", "php"}
+	if cfg.Services.Cron {
+		services = append(services, "cron")
+	}
+	return composeRun(services...)
+}
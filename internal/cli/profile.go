@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var profilesDir = filepath.Join(".wpdev", "profiles")
+
+var profileCmd = &cobra.Command{
+	Use:   "profile <url|path>",
+	Short: "Profile one request with Xdebug and summarize the slowest functions",
+	Long: `Profile one request with Xdebug and summarize the slowest functions.
+
+The request carries the XDEBUG_TRIGGER cookie; if the profiler is not already
+on, PHP is switched to profile mode for it and switched back afterwards. The
+cachegrind file is saved to .wpdev/profiles for KCachegrind/QCachegrind, and
+--format json prints the summary for comparing runs between commits.`,
+	Example: `  wpdev profile /
+  wpdev profile /shop/?orderby=price --sort self --top 30
+  wpdev profile /wp-admin/ --format json > before.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "table" && format != "json" {
+			return fmt.Errorf("unknown format %q, use table|json", format)
+		}
+		by, _ := cmd.Flags().GetString("sort")
+		if by != "incl" && by != "self" && by != "calls" {
+			return fmt.Errorf("unknown sort %q, use incl|self|calls", by)
+		}
+		top, _ := cmd.Flags().GetInt("top")
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		target, err := profileURL(cfg, args[0])
+		if err != nil { return err }
+
+		// Progress goes to stderr so --format json can be redirected
+		if !strings.Contains(cfg.XdebugMode(), "profile") {
+			tmp := *cfg
+			tmp.Xdebug.Mode, tmp.Xdebug.StartWithRequest = "profile", "trigger"
+			if _, err := syncPHPIni(&tmp); err != nil { return err }
+			fmt.Fprintln(os.Stderr, "Switching PHP to profile mode for one request...")
+			reloadPHP(&tmp)
+			time.Sleep(time.Second) // let the reload settle
+			// Switch back on Ctrl-C too, or .wpdev.yml and PHP disagree
+			var once sync.Once
+			restore := func() {
+				once.Do(func() {
+					if _, err := syncPHPIni(cfg); err == nil {
+						reloadPHP(cfg)
+					}
+				})
+			}
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				if _, ok := <-sigs; ok {
+					restore()
+					os.Exit(130)
+				}
+			}()
+			defer func() {
+				signal.Stop(sigs)
+				close(sigs)
+				restore()
+			}()
+		}
+
+		outDir := filepath.Join(".wpdev", "xdebug")
+		before := cachegrindFiles(outDir)
+		status, took, err := profileRequest(cfg, target)
+		if err != nil { return err }
+		fmt.Fprintf(os.Stderr, "GET %s -> %d in %s\n", target, status, took.Round(time.Millisecond))
+
+		src, err := waitForProfile(outDir, before, 10*time.Second)
+		if err != nil { return err }
+		dst, err := saveProfile(src, target)
+		if err != nil { return err }
+		p, err := readCachegrind(dst)
+		if err != nil { return err }
+		sortProfile(p, by)
+
+		if format == "json" {
+			return writeProfileJSON(os.Stdout, p, target, status, dst, top)
+		}
+		fmt.Printf("Profile: %s (total %s)\n\n", dst, fmtMs(p.Total))
+		return writeProfileTable(os.Stdout, p, top)
+	},
+}
+
+func init() {
+	profileCmd.Flags().String("format", "table", "output format: table|json")
+	profileCmd.Flags().String("sort", "incl", "order functions by incl|self|calls")
+	profileCmd.Flags().IntP("top", "n", 20, "number of functions to show (0 for all)")
+	rootCmd.AddCommand(profileCmd)
+}
+
+// profileURL turns a path into a URL on the site and refuses hosts the stack
+// does not serve, since requests always go to this machine.
+func profileURL(cfg *Config, arg string) (string, error) {
+	if !strings.Contains(arg, "://") {
+		return siteURL(cfg) + "/" + strings.TrimPrefix(arg, "/"), nil
+	}
+	u, err := url.Parse(arg)
+	if err != nil { return "", err }
+	host := u.Hostname()
+	if host == cfg.Domain || strings.HasSuffix(host, "."+cfg.Domain) {
+		return arg, nil
+	}
+	for _, d := range cfg.WordPress.Domains {
+		if host == d {
+			return arg, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not served by this project (%s)", host, cfg.Domain)
+}
+
+// profileRequest sends the request with the trigger cookie. Redirects are
+// not followed: each hop would write a profile of its own.
+func profileRequest(cfg *Config, target string) (int, time.Duration, error) {
+	client := localHTTPClient(cfg, 5*time.Minute)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil { return 0, 0, err }
+	req.AddCookie(&http.Cookie{Name: "XDEBUG_TRIGGER", Value: "wpdev"})
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w (is the stack running?)", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, time.Since(start), nil
+}
+
+func cachegrindFiles(dir string) map[string]bool {
+	seen := map[string]bool{}
+	matches, _ := filepath.Glob(filepath.Join(dir, "cachegrind.out.*"))
+	for _, m := range matches {
+		seen[m] = true
+	}
+	return seen
+}
+
+// waitForProfile returns the newest cachegrind file that was not in before,
+// once its size stops changing: PHP writes it after the response is sent.
+func waitForProfile(dir string, before map[string]bool, timeout time.Duration) (string, error) {
+	var last string
+	var lastSize int64 = -1
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(300 * time.Millisecond) {
+		var newest string
+		var newestInfo os.FileInfo
+		for m := range cachegrindFiles(dir) {
+			if before[m] {
+				continue
+			}
+			if fi, err := os.Stat(m); err == nil && (newestInfo == nil || fi.ModTime().After(newestInfo.ModTime())) {
+				newest, newestInfo = m, fi
+			}
+		}
+		if newest == "" {
+			continue
+		}
+		if newest == last && newestInfo.Size() == lastSize && lastSize > 0 {
+			return newest, nil
+		}
+		last, lastSize = newest, newestInfo.Size()
+	}
+	if last != "" {
+		return last, nil
+	}
+	return "", fmt.Errorf("no profile appeared in %s; check `wpdev xdebug status`", dir)
+}
+
+var profileSlug = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// saveProfile moves the profile to .wpdev/profiles, named after the time and
+// the URL path.
+func saveProfile(src, target string) (string, error) {
+	u, _ := url.Parse(target)
+	slug := "home"
+	if u != nil {
+		if s := strings.Trim(profileSlug.ReplaceAllString(u.Path, "-"), "-"); s != "" {
+			slug = s
+		}
+	}
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	name := time.Now().Format("20060102-150405") + "-" + slug + ".cachegrind"
+	if strings.HasSuffix(src, ".gz") {
+		name += ".gz"
+	}
+	if err := os.MkdirAll(profilesDir, 0o755); err != nil { return "", err }
+	dst := filepath.Join(profilesDir, name)
+	if err := os.Rename(src, dst); err != nil {
+		if err := copyFile(src, dst); err != nil { return "", err }
+		_ = os.Remove(src)
+	}
+	return dst, nil
+}
+
+func sortProfile(p *profile, by string) {
+	sort.SliceStable(p.Funcs, func(i, j int) bool {
+		a, b := p.Funcs[i], p.Funcs[j]
+		switch by {
+		case "self":
+			if a.Self != b.Self {
+				return a.Self > b.Self
+			}
+		case "calls":
+			if a.Calls != b.Calls {
+				return a.Calls > b.Calls
+			}
+		}
+		if a.Inclusive != b.Inclusive {
+			return a.Inclusive > b.Inclusive
+		}
+		return a.Name < b.Name
+	})
+}
+
+func topFuncs(p *profile, n int) []*profileFunc {
+	if n <= 0 || n > len(p.Funcs) {
+		return p.Funcs
+	}
+	return p.Funcs[:n]
+}
+
+func fmtMs(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+func pct(d, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(total)
+}
+
+func writeProfileTable(w io.Writer, p *profile, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "INCL\tINCL %\tSELF\tSELF %\tCALLS\t\tFUNCTION")
+	for _, f := range topFuncs(p, top) {
+		fmt.Fprintf(tw, "%s\t%.1f\t%s\t%.1f\t%d\t\t%s\n",
+			fmtMs(f.Inclusive), pct(f.Inclusive, p.Total), fmtMs(f.Self), pct(f.Self, p.Total), f.Calls, f.Name)
+	}
+	return tw.Flush()
+}
+
+type profileFuncJSON struct {
+	Name        string  `json:"name"`
+	File        string  `json:"file,omitempty"`
+	Calls       int64   `json:"calls"`
+	InclusiveMs float64 `json:"inclusive_ms"`
+	SelfMs      float64 `json:"self_ms"`
+}
+
+func writeProfileJSON(w io.Writer, p *profile, target string, status int, file string, top int) error {
+	out := struct {
+		URL       string            `json:"url"`
+		Status    int               `json:"status"`
+		Commit    string            `json:"commit,omitempty"`
+		Profile   string            `json:"profile"`
+		TotalMs   float64           `json:"total_ms"`
+		Functions []profileFuncJSON `json:"functions"`
+	}{URL: target, Status: status, Commit: gitCommit(), Profile: filepath.ToSlash(file), TotalMs: ms(p.Total)}
+	for _, f := range topFuncs(p, top) {
+		out.Functions = append(out.Functions, profileFuncJSON{f.Name, f.File, f.Calls, ms(f.Inclusive), ms(f.Self)})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// gitCommit is the checked-out commit, "" outside a git repository.
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil { return "" }
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pullCmd = &cobra.Command{
+	Use:   "pull <env>",
+	Short: "Pull database and/or uploads from a remote environment over SSH",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		env, err := lookupEnv(cfg, args[0])
+		if err != nil { return err }
+
+		doDB, _ := cmd.Flags().GetBool("db")
+		doFiles, _ := cmd.Flags().GetBool("files")
+		if !doDB && !doFiles {
+			doDB, doFiles = true, true
+		}
+
+		if doDB {
+			fmt.Printf("Pulling database from %s (%s -> %s)...\n", args[0], env.URL, siteURL(cfg))
+			if err := pullDB(cfg, env); err != nil { return err }
+		}
+		if doFiles {
+			fmt.Printf("Pulling uploads from %s...\n", args[0])
+			if err := rsyncUploads(cfg, env, false); err != nil { return err }
+		}
+		fmt.Println("Pull complete.")
+		return nil
+	},
+}
+
+var pushCmd = &cobra.Command{
+	Use:   "push <env>",
+	Short: "Push the local database and/or uploads to a remote environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		env, err := lookupEnv(cfg, name)
+		if err != nil { return err }
+		if env.Protected {
+			return fmt.Errorf("environment %q is protected: true in .wpdev.yml; refusing to push", name)
+		}
+
+		doDB, _ := cmd.Flags().GetBool("db")
+		doFiles, _ := cmd.Flags().GetBool("files")
+		if !doDB && !doFiles {
+			return fmt.Errorf("nothing to push: pass --db and/or --files")
+		}
+		if doDB && env.URL == "" {
+			return fmt.Errorf("environment %q needs url to rewrite %s on push", name, siteURL(cfg))
+		}
+
+		backup := fmt.Sprintf("~/wpdev-backups/%s-%s.sql", name, time.Now().Format("20060102-150405"))
+		fmt.Printf("Push plan for %s (%s:%s)\n", name, sshTarget(env), env.Path)
+		if doDB {
+			fmt.Printf("  1. back up the remote database to %s.gz\n", backup)
+			fmt.Printf("  2. dump the local database, rewrite %s -> %s\n", siteURL(cfg), env.URL)
+			fmt.Println("  3. REPLACE the remote database with it (wp db import)")
+		}
+		if doFiles {
+			fmt.Printf("  - upload %s/wp-content/uploads/ (new and changed files; nothing is deleted)\n", cfg.Web.Docroot)
+			if len(env.Excludes) > 0 {
+				fmt.Println("    excluding:", strings.Join(env.Excludes, ", "))
+			}
+		}
+		if ans := prompt(fmt.Sprintf("Type %q to write to this environment", name), ""); ans != name {
+			return fmt.Errorf("confirmation did not match; nothing was pushed")
+		}
+
+		if doDB {
+			fmt.Println("Backing up remote database...")
+			bk := sshCommand(env, "mkdir -p ~/wpdev-backups && cd "+shellQuote(env.Path)+
+				" && wp db export "+backup+" --single-transaction --quick && gzip "+backup)
+			bk.Stdout, bk.Stderr = os.Stdout, os.Stderr
+			if err := bk.Run(); err != nil {
+				return fmt.Errorf("remote backup failed, nothing was pushed: %w", err)
+			}
+			fmt.Println("Pushing database...")
+			if err := pushDB(cfg, env); err != nil {
+				return fmt.Errorf("%w (remote backup: %s.gz)", err, backup)
+			}
+		}
+		if doFiles {
+			fmt.Println("Pushing uploads...")
+			if err := rsyncUploads(cfg, env, true); err != nil { return err }
+		}
+		fmt.Println("Push complete.")
+		return nil
+	},
+}
+
+func init() {
+	pushCmd.Flags().Bool("db", false, "push the database (remote is backed up first)")
+	pushCmd.Flags().Bool("files", false, "push wp-content/uploads")
+	rootCmd.AddCommand(pushCmd)
+
+	pullCmd.Flags().Bool("db", false, "pull the database")
+	pullCmd.Flags().Bool("files", false, "pull wp-content/uploads")
+	rootCmd.AddCommand(pullCmd)
+}
+
+func lookupEnv(cfg *Config, name string) (EnvCfg, error) {
+	env, ok := cfg.Environments[name]
+	if !ok {
+		var names []string
+		for n := range cfg.Environments {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return env, fmt.Errorf("unknown environment %q (configured: %s)", name, strings.Join(names, ", "))
+	}
+	if env.Host == "" || env.Path == "" {
+		return env, fmt.Errorf("environment %q needs host and path", name)
+	}
+	return env, nil
+}
+
+// pullDB fetches `wp db export` from the server through the URL rewriter into
+// a private temp file and imports it once the server's end marker arrived, so
+// a dropped connection never leaves a half-imported local database.
+func pullDB(cfg *Config, env EnvCfg) error {
+	ssh := sshCommand(env, "cd "+shellQuote(env.Path)+" && wp db export - --single-transaction --quick && "+
+		`printf '\n%s\n' `+shellQuote(dumpEndMarker))
+	ssh.Stderr = os.Stderr
+	stdout, err := ssh.StdoutPipe()
+	if err != nil { return err }
+	f, err := os.CreateTemp("", "wpdev-pull-*.sql")
+	if err != nil { return err }
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := ssh.Start(); err != nil { return err }
+
+	// A MariaDB server's dump may start with a sandbox line the local client rejects
+	sw := &sandboxLineWriter{w: f}
+	if env.URL == "" {
+		_, err = io.Copy(sw, stdout)
+	} else {
+		err = newURLReplacer(env.URL, siteURL(cfg)).Copy(sw, stdout)
+	}
+	if err == nil { err = sw.Close() }
+	if err != nil { _ = ssh.Process.Kill() }
+	if werr := ssh.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("remote dump failed: %w", werr)
+	}
+	if err != nil { return err }
+
+	complete, err := endsWithDumpMarker(f)
+	if err != nil { return err }
+	if !complete {
+		return fmt.Errorf("incomplete dump from %s; the local database was not changed", env.Host)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil { return err }
+	return dbImport(cfg, f)
+}
+
+// pushDB streams a local dump, rewritten to the remote URL, into
+// `wp db import` on the server. The server buffers the stream and imports
+// only once the end marker arrived, so an aborted push never imports a
+// truncated dump.
+func pushDB(cfg *Config, env EnvCfg) error {
+	ssh := sshCommand(env, "cd "+shellQuote(env.Path)+` && f=$(mktemp) && trap 'rm -f "$f"' EXIT && cat > "$f" && `+
+		`{ [ "$(tail -n 1 "$f")" = `+shellQuote(dumpEndMarker)+` ] || { echo "incomplete dump; nothing imported" >&2; exit 1; }; } && `+
+		`wp db import "$f"`)
+	ssh.Stdout, ssh.Stderr = os.Stdout, os.Stderr
+	stdin, err := ssh.StdinPipe()
+	if err != nil { return err }
+	if err := ssh.Start(); err != nil { return err }
+
+	rep := newURLReplacer(siteURL(cfg), env.URL)
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		// The local dump selects the `wordpress` database; the remote name differs.
+		err := copyLines(stdin, pr, func(line string) (string, error) {
+			if strings.HasPrefix(line, "CREATE DATABASE ") || strings.HasPrefix(line, "USE `") {
+				return "", nil
+			}
+			return rep.line(line)
+		})
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	sw := &sandboxLineWriter{w: pw}
+	err = dbDump(cfg, sw, nil)
+	if err == nil { err = sw.Close() }
+	if err == nil {
+		_, err = io.WriteString(pw, "\n"+dumpEndMarker+"\n")
+	}
+	pw.CloseWithError(err)
+	if cerr := <-done; err == nil { err = cerr }
+	if err != nil {
+		// Kill before stdin closes: ssh must not pass on a clean EOF
+		_ = ssh.Process.Kill()
+	} else {
+		stdin.Close()
+	}
+	if werr := ssh.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("remote import failed: %w", werr)
+	}
+	return err
+}
+
+// dumpEndMarker closes a dump sent over SSH, in either direction; see pushDB
+// and pullDB.
+const dumpEndMarker = "-- wpdev: end of dump"
+
+// endsWithDumpMarker reports whether f's last line is dumpEndMarker.
+func endsWithDumpMarker(f *os.File) (bool, error) {
+	st, err := f.Stat()
+	if err != nil { return false, err }
+	off := st.Size() - int64(len(dumpEndMarker)+3)
+	if off < 0 {
+		off = 0
+	}
+	buf := make([]byte, st.Size()-off)
+	if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF { return false, err }
+	tail := "\n" + strings.TrimRight(string(buf), "\r\n")
+	return strings.HasSuffix(tail, "\n"+dumpEndMarker), nil
+}
+
+// rsyncUploads copies wp-content/uploads from (or, with push, to) env.
+func rsyncUploads(cfg *Config, env EnvCfg, push bool) error {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return fmt.Errorf("rsync not found; install it to sync files")
+	}
+	docroot := cfg.Web.Docroot
+	if docroot == "" {
+		docroot = "."
+	}
+	local := filepath.Join(docroot, "wp-content", "uploads") + "/"
+	remote := sshTarget(env) + ":" + strings.TrimRight(env.Path, "/") + "/wp-content/uploads/"
+	if err := os.MkdirAll(local, 0o755); err != nil { return err }
+
+	// rsync splits -e itself, honouring quotes, so key paths may contain spaces
+	var rsh []string
+	for _, a := range append([]string{"ssh"}, sshOptions(env)...) {
+		rsh = append(rsh, shellQuote(a))
+	}
+	version, _ := exec.Command("rsync", "--version").Output()
+	args := []string{"-az", rsyncProgressFlag(string(version)), "-e", strings.Join(rsh, " ")}
+	for _, ex := range env.Excludes {
+		args = append(args, "--exclude", ex)
+	}
+	if push {
+		args = append(args, local, remote)
+	} else {
+		args = append(args, remote, local)
+	}
+	c := exec.Command("rsync", args...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	return c.Run()
+}
+
+var rsyncVersion = regexp.MustCompile(`version v?(\d+)\.(\d+)`)
+
+// rsyncProgressFlag picks the progress option `rsync --version` supports:
+// --info=progress2 needs rsync 3.1, macOS ships 2.6.9 or openrsync.
+func rsyncProgressFlag(versionOutput string) string {
+	if m := rsyncVersion.FindStringSubmatch(versionOutput); m != nil {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		if major > 3 || major == 3 && minor >= 1 {
+			return "--info=progress2"
+		}
+	}
+	return "--progress"
+}
+
+func sshTarget(env EnvCfg) string {
+	if env.User == "" {
+		return env.Host
+	}
+	return env.User + "@" + env.Host
+}
+
+func sshOptions(env EnvCfg) []string {
+	opts := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new"}
+	if env.Port != 0 {
+		opts = append(opts, "-p", strconv.Itoa(env.Port))
+	}
+	if env.Key != "" {
+		opts = append(opts, "-i", expandHome(env.Key))
+	}
+	return opts
+}
+
+func sshCommand(env EnvCfg, script string) *exec.Cmd {
+	args := append(sshOptions(env), sshTarget(env), script)
+	return exec.Command("ssh", args...)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
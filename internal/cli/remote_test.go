@@ -0,0 +1,21 @@
+package cli
+
+import "testing"
+
+func TestRsyncProgressFlag(t *testing.T) {
+	tests := []struct {
+		name, out, want string
+	}{
+		{"rsync 3.2", "rsync  version 3.2.7  protocol version 31\nCopyright (C) 1996-2022 by Andrew Tridgell", "--info=progress2"},
+		{"rsync 3.1", "rsync  version v3.1.0  protocol version 31\n", "--info=progress2"},
+		{"rsync 3.0", "rsync  version 3.0.9  protocol version 30\n", "--progress"},
+		{"macOS rsync", "rsync  version 2.6.9  protocol version 29\n", "--progress"},
+		{"openrsync", "openrsync: protocol version 29\nrsync version 2.6.9 compatible\n", "--progress"},
+		{"unknown", "", "--progress"},
+	}
+	for _, tt := range tests {
+		if got := rsyncProgressFlag(tt.out); got != tt.want {
+			t.Errorf("%s: rsyncProgressFlag = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -11,6 +11,9 @@ import (
 
 var cfgFile string
 
+// version is stamped at build time: go build -ldflags "-X github.com/yourorg/wpdev/internal/cli.version=v1.2.3"
+var version = "dev"
+
 var rootCmd = &cobra.Command{
 	Use:   "wpdev",
 	Short: "Local WordPress dev environment manager",
@@ -25,6 +28,7 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.Version = version
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .wpdev.yml)")
 	cobra.OnInitialize(initConfig)
 
@@ -0,0 +1,453 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sanitizer rewrites a mysqldump stream (dumped with --complete-insert) line
+// by line, applying the configured rules to every INSERT statement.
+type Sanitizer struct {
+	rules    map[string][]SanitizeRule // by table
+	now      time.Time
+	password string
+}
+
+func newSanitizer(cfg SanitizeCfg) (*Sanitizer, error) {
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "wp_"
+	}
+	var all []SanitizeRule
+	for _, p := range cfg.Presets {
+		rules, ok := sanitizePresets(prefix)[p]
+		if !ok {
+			return nil, fmt.Errorf("unknown sanitize preset %q (use wordpress|woocommerce)", p)
+		}
+		all = append(all, rules...)
+	}
+	all = append(all, cfg.Rules...)
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no sanitize rules: set database.sanitize.presets or database.sanitize.rules in .wpdev.yml")
+	}
+
+	s := &Sanitizer{rules: map[string][]SanitizeRule{}, now: time.Now(), password: cfg.Password}
+	if s.password == "" {
+		s.password = "password"
+	}
+	for _, r := range all {
+		if r.Table == "" {
+			return nil, fmt.Errorf("sanitize rule without table")
+		}
+		switch r.Action {
+		case "truncate":
+		case "delete":
+			if r.OlderThan != "" && r.DateColumn == "" {
+				return nil, fmt.Errorf("sanitize rule for %s: older_than needs date_column", r.Table)
+			}
+			if _, err := parseAge(r.OlderThan); err != nil {
+				return nil, fmt.Errorf("sanitize rule for %s: %w", r.Table, err)
+			}
+		case "email", "hash", "password", "null", "value":
+			if r.Column == "" {
+				return nil, fmt.Errorf("sanitize rule for %s: action %q needs a column", r.Table, r.Action)
+			}
+		default:
+			return nil, fmt.Errorf("sanitize rule for %s: unknown action %q", r.Table, r.Action)
+		}
+		s.rules[r.Table] = append(s.rules[r.Table], r)
+	}
+	return s, nil
+}
+
+// sanitizePresets returns the built-in rule sets keyed by preset name.
+func sanitizePresets(prefix string) map[string][]SanitizeRule {
+	wp := []SanitizeRule{
+		{Table: prefix + "users", Column: "user_email", Action: "email"},
+		{Table: prefix + "users", Column: "user_pass", Action: "password"},
+		{Table: prefix + "users", Column: "user_activation_key", Action: "value"},
+		{Table: prefix + "comments", Column: "comment_author_email", Action: "email"},
+		{Table: prefix + "comments", Column: "comment_author_IP", Action: "value"},
+		{Table: prefix + "usermeta", Column: "meta_value", Action: "value", Match: map[string]string{"meta_key": "session_tokens"}},
+	}
+
+	wc := []SanitizeRule{
+		{Table: prefix + "woocommerce_sessions", Action: "truncate"},
+		{Table: prefix + "wc_orders", Column: "billing_email", Action: "email"},
+		{Table: prefix + "wc_orders", Column: "ip_address", Action: "value"},
+		{Table: prefix + "wc_order_addresses", Column: "email", Action: "email"},
+		{Table: prefix + "wc_customer_lookup", Column: "email", Action: "email"},
+	}
+	for _, col := range []string{"first_name", "last_name", "company", "address_1", "address_2", "phone"} {
+		wc = append(wc, SanitizeRule{Table: prefix + "wc_order_addresses", Column: col, Action: "value"})
+	}
+	for _, kind := range []string{"billing", "shipping"} {
+		wc = append(wc,
+			SanitizeRule{Table: prefix + "usermeta", Column: "meta_value", Action: "email", Match: map[string]string{"meta_key": kind + "_email"}},
+			SanitizeRule{Table: prefix + "postmeta", Column: "meta_value", Action: "email", Match: map[string]string{"meta_key": "_" + kind + "_email"}},
+		)
+		for _, f := range []string{"first_name", "last_name", "company", "address_1", "address_2", "phone"} {
+			wc = append(wc,
+				SanitizeRule{Table: prefix + "usermeta", Column: "meta_value", Action: "value", Match: map[string]string{"meta_key": kind + "_" + f}},
+				SanitizeRule{Table: prefix + "postmeta", Column: "meta_value", Action: "value", Match: map[string]string{"meta_key": "_" + kind + "_" + f}},
+			)
+		}
+	}
+	wc = append(wc, SanitizeRule{Table: prefix + "postmeta", Column: "meta_value", Action: "value", Match: map[string]string{"meta_key": "_customer_ip_address"}})
+
+	return map[string][]SanitizeRule{"wordpress": wp, "woocommerce": wc}
+}
+
+// Copy streams a dump from r to w, rewriting INSERT statements as it goes.
+func (s *Sanitizer) Copy(w io.Writer, r io.Reader) error {
+	return copyLines(w, r, s.line)
+}
+
+// copyLines streams r to w one line at a time through fn. mysqldump writes
+// each (extended) INSERT statement on a single line.
+func copyLines(w io.Writer, r io.Reader, fn func(string) (string, error)) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	bw := bufio.NewWriterSize(w, 1<<20)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			out, lerr := fn(line)
+			if lerr != nil {
+				return lerr
+			}
+			if _, werr := bw.WriteString(out); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (s *Sanitizer) line(line string) (string, error) {
+	if !strings.HasPrefix(line, "INSERT INTO `") {
+		return line, nil
+	}
+	rest := line[len("INSERT INTO `"):]
+	end := strings.IndexByte(rest, '`')
+	if end < 0 {
+		return line, nil
+	}
+	table := rest[:end]
+	rules := s.rules[table]
+	if len(rules) == 0 {
+		return line, nil
+	}
+	for _, r := range rules {
+		if r.Action == "truncate" {
+			return "", nil
+		}
+	}
+
+	stmt, err := parseInsert(line)
+	if err == nil && stmt.columns == nil {
+		err = fmt.Errorf("INSERT without column list")
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w (was the dump made with --complete-insert?)", table, err)
+	}
+	idx := map[string]int{}
+	for i, c := range stmt.columns {
+		idx[c] = i
+	}
+
+	kept := stmt.rows[:0]
+rows:
+	for _, row := range stmt.rows {
+		for _, r := range rules {
+			if !r.matches(row, idx) {
+				continue
+			}
+			if r.Action == "delete" {
+				old, err := r.isOlder(row, idx, s.now)
+				if err != nil {
+					return "", fmt.Errorf("%s: %w", table, err)
+				}
+				if old {
+					continue rows
+				}
+				continue
+			}
+			i, ok := idx[r.Column]
+			if !ok {
+				return "", fmt.Errorf("%s: unknown column %q in sanitize rule", table, r.Column)
+			}
+			row[i] = s.apply(r, row[i])
+		}
+		kept = append(kept, row)
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+	stmt.rows = kept
+	return stmt.String(), nil
+}
+
+func (s *Sanitizer) apply(r SanitizeRule, v sqlValue) sqlValue {
+	switch r.Action {
+	case "null":
+		return sqlNull()
+	case "value":
+		return sqlString(r.Value)
+	case "password":
+		sum := md5.Sum([]byte(s.password)) // WordPress accepts and upgrades plain MD5 on login
+		return sqlString(hex.EncodeToString(sum[:]))
+	}
+	if v.null || v.str == "" {
+		return v
+	}
+	switch r.Action {
+	case "email":
+		sum := sha1.Sum([]byte(strings.ToLower(v.str)))
+		return sqlString("user-" + hex.EncodeToString(sum[:5]) + "@example.test")
+	case "hash":
+		sum := sha256.Sum256([]byte(v.str))
+		return sqlString(hex.EncodeToString(sum[:]))
+	}
+	return v
+}
+
+func (r SanitizeRule) matches(row []sqlValue, idx map[string]int) bool {
+	for col, want := range r.Match {
+		i, ok := idx[col]
+		if !ok || row[i].null || row[i].str != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (r SanitizeRule) isOlder(row []sqlValue, idx map[string]int, now time.Time) (bool, error) {
+	if r.OlderThan == "" {
+		return true, nil
+	}
+	i, ok := idx[r.DateColumn]
+	if !ok {
+		return false, fmt.Errorf("unknown date column %q in sanitize rule", r.DateColumn)
+	}
+	if row[i].null {
+		return false, nil
+	}
+	age, _ := parseAge(r.OlderThan)
+	cutoff := now.Add(-age).UTC().Format("2006-01-02 15:04:05")
+	// MySQL DATETIME values sort lexically; zero dates never count as old.
+	return !strings.HasPrefix(row[i].str, "0000") && row[i].str < cutoff, nil
+}
+
+// parseAge accepts Go durations plus d (days) and w (weeks) suffixes.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mult := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		mult = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		mult = 7 * 24 * time.Hour
+	}
+	if mult > 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid older_than %q", s)
+		}
+		return time.Duration(n) * mult, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid older_than %q", s)
+	}
+	return d, nil
+}
+
+// ----- mysqldump INSERT parsing -----
+
+type sqlValue struct {
+	raw    string // as it appeared in the dump; empty when rewritten
+	str    string // decoded string/number
+	null   bool
+	quoted bool
+}
+
+func sqlNull() sqlValue           { return sqlValue{null: true} }
+func sqlString(s string) sqlValue { return sqlValue{str: s, quoted: true} }
+
+func (v sqlValue) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	if v.null {
+		return "NULL"
+	}
+	if !v.quoted {
+		return v.str
+	}
+	return "'" + sqlEscape(v.str) + "'"
+}
+
+type insertStmt struct {
+	head    string // "INSERT INTO `t` (`a`, `b`) VALUES "
+	columns []string
+	rows    [][]sqlValue
+}
+
+func (st *insertStmt) String() string {
+	var b strings.Builder
+	b.WriteString(st.head)
+	for i, row := range st.rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(v.String())
+		}
+		b.WriteByte(')')
+	}
+	b.WriteString(";\n")
+	return b.String()
+}
+
+func parseInsert(line string) (*insertStmt, error) {
+	if !strings.HasPrefix(line, "INSERT INTO `") {
+		return nil, fmt.Errorf("not an INSERT")
+	}
+	end := strings.IndexByte(line[len("INSERT INTO `"):], '`') + len("INSERT INTO `") + 1
+	st := &insertStmt{}
+	var s string
+	switch rest := line[end:]; {
+	case strings.HasPrefix(rest, " VALUES ("):
+		st.head = line[:end+len(" VALUES ")]
+		s = rest[len(" VALUES "):]
+	case strings.HasPrefix(rest, " ("):
+		vi := strings.Index(rest, ") VALUES (")
+		if vi < 0 {
+			return nil, fmt.Errorf("malformed column list")
+		}
+		for _, c := range strings.Split(rest[2:vi], ",") {
+			st.columns = append(st.columns, strings.Trim(strings.TrimSpace(c), "`"))
+		}
+		st.head = line[:end+vi+len(") VALUES ")]
+		s = rest[vi+len(") VALUES "):]
+	default:
+		return nil, fmt.Errorf("malformed INSERT")
+	}
+
+	i := 0
+	for i < len(s) {
+		if s[i] != '(' {
+			return nil, fmt.Errorf("unexpected %q in VALUES", s[i])
+		}
+		i++
+		var row []sqlValue
+		for {
+			var v sqlValue
+			start := i
+			if i < len(s) && s[i] == '\'' {
+				var b strings.Builder
+				i++
+				for i < len(s) && s[i] != '\'' {
+					if s[i] == '\\' && i+1 < len(s) {
+						b.WriteByte(sqlUnescape(s[i+1]))
+						i += 2
+						continue
+					}
+					b.WriteByte(s[i])
+					i++
+				}
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				i++
+				v = sqlValue{str: b.String(), quoted: true}
+			} else {
+				for i < len(s) && s[i] != ',' && s[i] != ')' {
+					i++
+				}
+				tok := s[start:i]
+				v = sqlValue{str: tok, null: tok == "NULL"}
+			}
+			v.raw = s[start:i]
+			row = append(row, v)
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated row")
+			}
+			if s[i] == ')' {
+				i++
+				break
+			}
+			i++ // ','
+		}
+		if st.columns != nil && len(row) != len(st.columns) {
+			return nil, fmt.Errorf("row has %d values for %d columns", len(row), len(st.columns))
+		}
+		st.rows = append(st.rows, row)
+		if i < len(s) && s[i] == ',' {
+			i++
+			continue
+		}
+		break
+	}
+	return st, nil
+}
+
+func sqlUnescape(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'Z':
+		return 26
+	}
+	return c
+}
+
+func sqlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 26:
+			b.WriteString(`\Z`)
+		case '\'', '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInsert(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		columns []string
+		rows    [][]string // decoded values, "NULL" for SQL NULL
+		err     string
+	}{
+		{
+			name:    "complete insert",
+			line:    "INSERT INTO `wp_users` (`ID`, `user_email`) VALUES (1,'a@b.c'),(2,NULL);\n",
+			columns: []string{"ID", "user_email"},
+			rows:    [][]string{{"1", "a@b.c"}, {"2", "NULL"}},
+		},
+		{
+			name: "without column list",
+			line: "INSERT INTO `wp_options` VALUES (1,'siteurl');\n",
+			rows: [][]string{{"1", "siteurl"}},
+		},
+		{
+			name:    "escapes and separators inside strings",
+			line:    `INSERT INTO ` + "`t` (`a`) VALUES ('it\\'s, (a) \\\\ \\n test');\n",
+			columns: []string{"a"},
+			rows:    [][]string{{"it's, (a) \\ \n test"}},
+		},
+		{name: "not an insert", line: "CREATE TABLE `t` (\n", err: "not an INSERT"},
+		{name: "unterminated string", line: "INSERT INTO `t` VALUES ('abc", err: "unterminated string"},
+		{name: "column count", line: "INSERT INTO `t` (`a`, `b`) VALUES (1);\n", err: "row has 1 values for 2 columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := parseInsert(tt.line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(st.columns, ",") != strings.Join(tt.columns, ",") {
+				t.Errorf("columns = %q, want %q", st.columns, tt.columns)
+			}
+			if len(st.rows) != len(tt.rows) {
+				t.Fatalf("got %d rows, want %d", len(st.rows), len(tt.rows))
+			}
+			for i, row := range st.rows {
+				for j, v := range row {
+					got := v.str
+					if v.null {
+						got = "NULL"
+					}
+					if got != tt.rows[i][j] {
+						t.Errorf("row %d value %d = %q, want %q", i, j, got, tt.rows[i][j])
+					}
+				}
+			}
+			// Untouched values are written back exactly as dumped
+			if got := st.String(); got != tt.line {
+				t.Errorf("String() = %q, want %q", got, tt.line)
+			}
+		})
+	}
+}
+
+func TestSanitizer(t *testing.T) {
+	old := time.Now().AddDate(-2, 0, 0).UTC().Format("2006-01-02 15:04:05")
+	recent := time.Now().UTC().Format("2006-01-02 15:04:05")
+	tests := []struct {
+		name string
+		cfg  SanitizeCfg
+		in   string
+		out  string
+	}{
+		{
+			name: "wordpress preset",
+			cfg:  SanitizeCfg{Presets: []string{"wordpress"}},
+			in:   "INSERT INTO `wp_users` (`ID`, `user_email`, `user_pass`, `user_activation_key`) VALUES (1,'Admin@Client.com','$P$x','key');\n",
+			out:  "INSERT INTO `wp_users` (`ID`, `user_email`, `user_pass`, `user_activation_key`) VALUES (1,'user-e922cab32d@example.test','5f4dcc3b5aa765d61d8327deb882cf99','');\n",
+		},
+		{
+			name: "empty and NULL emails stay",
+			cfg:  SanitizeCfg{Presets: []string{"wordpress"}},
+			in:   "INSERT INTO `wp_comments` (`comment_ID`, `comment_author_email`, `comment_author_IP`) VALUES (1,'',NULL),(2,NULL,'1.2.3.4');\n",
+			out:  "INSERT INTO `wp_comments` (`comment_ID`, `comment_author_email`, `comment_author_IP`) VALUES (1,'',''),(2,NULL,'');\n",
+		},
+		{
+			name: "match limits the rows",
+			cfg:  SanitizeCfg{Prefix: "x_", Presets: []string{"wordpress"}},
+			in:   "INSERT INTO `x_usermeta` (`umeta_id`, `meta_key`, `meta_value`) VALUES (1,'session_tokens','a:1:{}'),(2,'nickname','bob');\n",
+			out:  "INSERT INTO `x_usermeta` (`umeta_id`, `meta_key`, `meta_value`) VALUES (1,'session_tokens',''),(2,'nickname','bob');\n",
+		},
+		{
+			name: "truncate drops the statement",
+			cfg:  SanitizeCfg{Presets: []string{"woocommerce"}},
+			in:   "INSERT INTO `wp_woocommerce_sessions` (`session_id`) VALUES (1);\n",
+			out:  "",
+		},
+		{
+			name: "delete older than",
+			cfg:  SanitizeCfg{Rules: []SanitizeRule{{Table: "wp_posts", Action: "delete", DateColumn: "post_date", OlderThan: "365d"}}},
+			in:   "INSERT INTO `wp_posts` (`ID`, `post_date`) VALUES (1,'" + old + "'),(2,'" + recent + "'),(3,'0000-00-00 00:00:00');\n",
+			out:  "INSERT INTO `wp_posts` (`ID`, `post_date`) VALUES (2,'" + recent + "'),(3,'0000-00-00 00:00:00');\n",
+		},
+		{
+			name: "other tables and lines pass through",
+			cfg:  SanitizeCfg{Presets: []string{"wordpress"}},
+			in:   "-- comment\nINSERT INTO `wp_options` VALUES (1,'admin_email','a@b.c');\n",
+			out:  "-- comment\nINSERT INTO `wp_options` VALUES (1,'admin_email','a@b.c');\n",
+		},
+		{
+			name: "custom value and quoting",
+			cfg:  SanitizeCfg{Rules: []SanitizeRule{{Table: "t", Column: "note", Action: "value", Value: "it's"}}},
+			in:   "INSERT INTO `t` (`note`) VALUES ('secret');\n",
+			out:  "INSERT INTO `t` (`note`) VALUES ('it\\'s');\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSanitizer(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := s.Copy(&out, strings.NewReader(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.out {
+				t.Errorf("got  %q\nwant %q", out.String(), tt.out)
+			}
+		})
+	}
+}
+
+func TestSanitizerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SanitizeCfg
+		in   string
+		err  string
+	}{
+		{name: "no rules", cfg: SanitizeCfg{}, err: "no sanitize rules"},
+		{name: "unknown preset", cfg: SanitizeCfg{Presets: []string{"drupal"}}, err: "unknown sanitize preset"},
+		{name: "column missing", cfg: SanitizeCfg{Rules: []SanitizeRule{{Table: "t", Action: "email"}}}, err: "needs a column"},
+		{name: "older_than without date_column", cfg: SanitizeCfg{Rules: []SanitizeRule{{Table: "t", Action: "delete", OlderThan: "1d"}}}, err: "needs date_column"},
+		{name: "bad age", cfg: SanitizeCfg{Rules: []SanitizeRule{{Table: "t", Action: "delete", DateColumn: "d", OlderThan: "soon"}}}, err: "invalid older_than"},
+		{
+			name: "dump without column list",
+			cfg:  SanitizeCfg{Presets: []string{"wordpress"}},
+			in:   "INSERT INTO `wp_users` VALUES (1,'a@b.c');\n",
+			err:  "--complete-insert",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSanitizer(tt.cfg)
+			if err == nil {
+				err = s.Copy(&bytes.Buffer{}, strings.NewReader(tt.in))
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
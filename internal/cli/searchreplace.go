@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// urlReplacer rewrites one site URL into another inside a SQL dump, fixing
+// the byte lengths of PHP-serialized strings (options, postmeta, widgets)
+// that contain it.
+type urlReplacer struct {
+	pairs []string // old, new, old, new, ...
+}
+
+// newURLReplacer maps from (e.g. https://www.client.com) onto to, covering
+// the full URL, protocol-relative //host and JSON-escaped forms.
+func newURLReplacer(from, to string) *urlReplacer {
+	from, to = strings.TrimRight(from, "/"), strings.TrimRight(to, "/")
+	pairs := []string{from, to}
+	fu, ferr := url.Parse(from)
+	tu, terr := url.Parse(to)
+	if ferr == nil && terr == nil && fu.Host != "" && tu.Host != "" {
+		pairs = append(pairs,
+			strings.ReplaceAll(from, "/", `\/`), strings.ReplaceAll(to, "/", `\/`),
+			"//"+fu.Host, "//"+tu.Host,
+			`\/\/`+fu.Host, `\/\/`+tu.Host,
+		)
+	}
+	return &urlReplacer{pairs: pairs}
+}
+
+func (u *urlReplacer) Copy(w io.Writer, r io.Reader) error {
+	return copyLines(w, r, u.line)
+}
+
+func (u *urlReplacer) contains(s string) bool {
+	for i := 0; i < len(u.pairs); i += 2 {
+		if strings.Contains(s, u.pairs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *urlReplacer) line(line string) (string, error) {
+	if !u.contains(line) {
+		return line, nil
+	}
+	stmt, err := parseInsert(line)
+	if err != nil {
+		// Not row data (comments, DDL); a plain replace is safe there.
+		return u.replaceAll(line), nil
+	}
+	for _, row := range stmt.rows {
+		for i, v := range row {
+			if !v.quoted || !u.contains(v.str) {
+				continue
+			}
+			row[i] = sqlString(u.replace(v.str))
+		}
+	}
+	return stmt.String(), nil
+}
+
+// replace rewrites s, recursing into PHP-serialized data so that every
+// s:N:"..." length stays correct.
+func (u *urlReplacer) replace(s string) string {
+	if !looksSerialized(s) {
+		return u.replaceAll(s)
+	}
+	out, ok := u.reserialize(s)
+	if !ok {
+		return u.replaceAll(s)
+	}
+	return out
+}
+
+// replaceAll replaces every pair that is not followed by more of a host name,
+// so client.com leaves client.com.au and client.community alone.
+func (u *urlReplacer) replaceAll(s string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(s); i++ {
+		for p := 0; p < len(u.pairs); p += 2 {
+			old := u.pairs[p]
+			if !strings.HasPrefix(s[i:], old) || (i+len(old) < len(s) && isHostByte(s[i+len(old)])) {
+				continue
+			}
+			b.WriteString(s[last:i])
+			b.WriteString(u.pairs[p+1])
+			i += len(old) - 1
+			last = i + 1
+			break
+		}
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isHostByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+func looksSerialized(s string) bool {
+	if len(s) < 4 || s[1] != ':' {
+		return s == "N;"
+	}
+	return strings.IndexByte("aOsibdNC", s[0]) >= 0
+}
+
+// reserialize walks serialized PHP, replacing inside every string token.
+func (u *urlReplacer) reserialize(s string) (string, bool) {
+	var b strings.Builder
+	i := 0
+	for i < len(s) {
+		if s[i] == 's' && i+1 < len(s) && s[i+1] == ':' {
+			j := strings.IndexByte(s[i+2:], ':')
+			if j < 0 {
+				return "", false
+			}
+			n, err := strconv.Atoi(s[i+2 : i+2+j])
+			start := i + 2 + j + 2 // skip `:"`
+			if err != nil || start > len(s) || start+n+2 > len(s) || s[start-1] != '"' || s[start+n:start+n+2] != `";` {
+				return "", false
+			}
+			inner := u.replace(s[start : start+n])
+			b.WriteString("s:" + strconv.Itoa(len(inner)) + `:"` + inner + `";`)
+			i = start + n + 2
+			continue
+		}
+		// Copy any other token up to and including its terminator.
+		j := strings.IndexAny(s[i:], ";{}")
+		if j < 0 {
+			return "", false
+		}
+		b.WriteString(s[i : i+j+1])
+		i += j + 1
+	}
+	return b.String(), true
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestURLReplacerReplace(t *testing.T) {
+	u := newURLReplacer("https://www.client.com/", "http://client.test")
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"plain", "see https://www.client.com/shop", "see http://client.test/shop"},
+		{"protocol relative", `<img src="//www.client.com/a.png">`, `<img src="//client.test/a.png">`},
+		{"json escaped", `{"url":"https:\/\/www.client.com\/x"}`, `{"url":"http:\/\/client.test\/x"}`},
+		{"serialized string", `s:26:"https://www.client.com/img";`, `s:22:"http://client.test/img";`},
+		{
+			"serialized array",
+			`a:2:{s:3:"url";s:22:"https://www.client.com";i:0;s:4:"keep";}`,
+			`a:2:{s:3:"url";s:18:"http://client.test";i:0;s:4:"keep";}`,
+		},
+		{
+			"nested serialized string",
+			`a:1:{i:0;s:40:"a:1:{i:0;s:22:"https://www.client.com";}";}`,
+			`a:1:{i:0;s:36:"a:1:{i:0;s:18:"http://client.test";}";}`,
+		},
+		{
+			"multibyte counts bytes",
+			`s:25:"é https://www.client.com";`,
+			`s:21:"é http://client.test";`,
+		},
+		{"longer host", "https://www.client.com.au/x and //www.client.community/y", "https://www.client.com.au/x and //www.client.community/y"},
+		{"subdomain label", "https://www.client.com-staging.net/", "https://www.client.com-staging.net/"},
+		{"port and query", "//www.client.com:8443/a?u=https://www.client.com", "//client.test:8443/a?u=http://client.test"},
+		{"serialized longer host", `s:25:"https://www.client.com.au";`, `s:25:"https://www.client.com.au";`},
+		{
+			// A wrong length means the data is not really serialized; it is
+			// replaced like plain text rather than dropped
+			"broken length",
+			`s:99:"https://www.client.com";`,
+			`s:99:"http://client.test";`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := u.replace(tt.in); got != tt.out {
+				t.Errorf("replace(%q) = %q, want %q", tt.in, got, tt.out)
+			}
+		})
+	}
+}
+
+func TestURLReplacerHostBoundary(t *testing.T) {
+	u := newURLReplacer("https://client.com", "http://client.test")
+	for in, want := range map[string]string{
+		"https://client.com.au/x": "https://client.com.au/x",
+		"//client.community/y":    "//client.community/y",
+		"https://client.com/x":    "http://client.test/x",
+		"//client.com":            "//client.test",
+		`https:\/\/client.com\/x`: `http:\/\/client.test\/x`,
+	} {
+		if got := u.replace(in); got != want {
+			t.Errorf("replace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestURLReplacerCopy(t *testing.T) {
+	u := newURLReplacer("https://www.client.com", "http://client.test")
+	in := "-- Host: https://www.client.com\n" +
+		"INSERT INTO `wp_options` VALUES (1,'siteurl','https://www.client.com'),(2,'widget','a:1:{s:3:\\\"url\\\";s:22:\\\"https://www.client.com\\\";}');\n" +
+		"INSERT INTO `wp_options` VALUES (3,'blogname','Client');\n"
+	want := "-- Host: http://client.test\n" +
+		"INSERT INTO `wp_options` VALUES (1,'siteurl','http://client.test'),(2,'widget','a:1:{s:3:\\\"url\\\";s:18:\\\"http://client.test\\\";}');\n" +
+		"INSERT INTO `wp_options` VALUES (3,'blogname','Client');\n"
+	var out bytes.Buffer
+	if err := u.Copy(&out, strings.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("got  %q\nwant %q", out.String(), want)
+	}
+}
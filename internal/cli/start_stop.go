@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -15,20 +16,63 @@ var startCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig(".wpdev.yml")
 		if err != nil { return err }
+		if err := startStack(cfg); err != nil { return err }
 
-		// Ensure generated dir
-		gen := filepath.Join(".wpdev", "generated")
-		if err := os.MkdirAll(gen, 0o755); err != nil { return err }
+		// Swap in the database of the checked-out branch
+		if cfg.Database.PerBranch {
+			branch, err := gitBranch()
+			switch {
+			case errors.Is(err, errDetachedHead):
+				// rebase or bisect in progress: keep whatever database is loaded
+				fmt.Println("Detached HEAD; keeping the database of branch", loadBranchState())
+			case err != nil:
+				return err
+			default:
+				if err := switchDBBranch(cfg, branch); err != nil { return err }
+			}
+		}
+		return setupSite(cfg)
+	},
+}
 
-		// Render nginx.conf and docker-compose.yml
-		if err := renderTemplates(cfg); err != nil { return err }
+// startStack renders the templates and brings the containers up, without
+// touching the site itself.
+func startStack(cfg *Config) error {
+	// Ensure generated dir
+	gen := filepath.Join(".wpdev", "generated")
+	if err := os.MkdirAll(gen, 0o755); err != nil { return err }
 
-		// docker compose up -d
-		fmt.Println("Bringing up containers...")
-		c := exec.Command("docker", "compose", "up", "-d")
-		c.Stdout = os.Stdout; c.Stderr = os.Stderr
-		return c.Run()
-	},
+	// Refuse to boot a different engine/version on existing data
+	if err := checkDBEngine(cfg); err != nil { return err }
+	if err := checkMounts(cfg); err != nil { return err }
+	for _, d := range cfg.WordPress.Domains {
+		if err := registerHost(d); err != nil { return err }
+	}
+
+	// Render nginx.conf and docker-compose.yml
+	if err := renderTemplates(cfg); err != nil { return err }
+	iniChanged, err := syncPHPIni(cfg)
+	if err != nil { return err }
+
+	// docker compose up -d
+	fmt.Println("Bringing up containers...")
+	c := exec.Command("docker", "compose", "up", "-d")
+	c.Stdout = os.Stdout; c.Stderr = os.Stderr
+	if err := c.Run(); err != nil { return err }
+	if iniChanged {
+		if err := restartPHP(cfg); err != nil { return err }
+	}
+	return saveEngineRecord(cfg)
+}
+
+// setupSite installs WordPress on first start and syncs plugins and themes.
+func setupSite(cfg *Config) error {
+	// First start: download core, write wp-config.php, install
+	if err := installWordPress(cfg); err != nil { return err }
+
+	// Plugins and themes from wordpress.plugins / wordpress.themes
+	_, err := syncPackages(cfg, false)
+	return err
 }
 
 var stopCmd = &cobra.Command{
@@ -48,10 +92,20 @@ var rebuildCmd = &cobra.Command{
 		// Re-render templates in case config changed
 		cfg, err := loadConfig(".wpdev.yml")
 		if err != nil { return err }
+		if err := checkDBEngine(cfg); err != nil { return err }
+		if err := checkMounts(cfg); err != nil { return err }
 		if err := renderTemplates(cfg); err != nil { return err }
+		iniChanged, err := syncPHPIni(cfg)
+		if err != nil { return err }
 
 		c := exec.Command("docker", "compose", "up", "-d", "--build", "--remove-orphans")
 		c.Stdout = os.Stdout; c.Stderr = os.Stderr
-		return c.Run()
+		if err := c.Run(); err != nil { return err }
+		// After up: with multisite the network constants depend on the database
+		if err := syncWPConfig(cfg); err != nil { return err }
+		if iniChanged {
+			if err := restartPHP(cfg); err != nil { return err }
+		}
+		return saveEngineRecord(cfg)
 	},
 }
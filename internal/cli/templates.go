@@ -2,9 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
+
+	"gopkg.in/yaml.v3"
 )
 
 func renderTemplates(cfg *Config) error {
@@ -28,6 +32,7 @@ func renderTemplates(cfg *Config) error {
     out.Reset()
     if err := phpTpl.Execute(&out, cfg); err != nil { return err }
     if err := os.WriteFile(filepath.Join(".wpdev", "generated", "php.Dockerfile"), out.Bytes(), 0o644); err != nil { return err }
+    cfg.phpImage = phpImageTag(cfg, out.Bytes())
 
 	// docker-compose
 	content, err = os.ReadFile(filepath.Join(tplDir, "docker-compose.tmpl.yml"))
@@ -53,5 +58,104 @@ func renderTemplates(cfg *Config) error {
 	if err := caddyTpl.Execute(&out, cfg); err != nil { return err }
 	if err := os.WriteFile(filepath.Join(".wpdev", "generated", "Caddyfile"), out.Bytes(), 0o644); err != nil { return err }
 
+	// Uploads cache in front of media_proxy
+	if cfg.MediaProxy != "" && cfg.MediaProxyCache {
+		if err := renderTemplate(tplDir, "media-cache.conf.tmpl", mediaCacheConfTemplate, cfg,
+			filepath.Join(".wpdev", "generated", "media-cache.conf")); err != nil { return err }
+	}
+
 	return nil
 }
+
+// renderTemplate renders .wpdev/templates/<name> to dst, falling back to the
+// built-in template for projects initialized before it existed.
+func renderTemplate(tplDir, name, builtin string, cfg *Config, dst string) error {
+	content, err := os.ReadFile(filepath.Join(tplDir, name))
+	if os.IsNotExist(err) {
+		content, err = []byte(builtin), nil
+	}
+	if err != nil { return err }
+	tpl, err := template.New(name).Parse(string(content))
+	if err != nil { return err }
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, cfg); err != nil { return err }
+	return os.WriteFile(dst, out.Bytes(), 0o644)
+}
+
+// renderedCompose is docker-compose.yml as last rendered. Templates are only
+// written once per project, so features added to the built-in template later
+// may be missing from it.
+type renderedCompose struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string `yaml:"image"`
+	Volumes     []any  `yaml:"volumes"`     // "src:target[:mode]" or long syntax
+	Environment any    `yaml:"environment"` // ["K=V"] or {K: V}
+	ExtraHosts  any    `yaml:"extra_hosts"`  // ["host:ip"] or {host: ip}
+}
+
+// env returns the value the service sets for name.
+func (s composeService) env(name string) (string, bool) {
+	switch e := s.Environment.(type) {
+	case []any:
+		for _, kv := range e {
+			if k, v, _ := strings.Cut(fmt.Sprint(kv), "="); k == name {
+				return v, true
+			}
+		}
+	case map[string]any:
+		if v, ok := e[name]; ok {
+			return fmt.Sprint(v), true
+		}
+	}
+	return "", false
+}
+
+// mapsHost reports whether extra_hosts maps name.
+func (s composeService) mapsHost(name string) bool {
+	switch h := s.ExtraHosts.(type) {
+	case []any:
+		for _, kv := range h {
+			if k, _, _ := strings.Cut(fmt.Sprint(kv), ":"); strings.TrimSpace(k) == name {
+				return true
+			}
+		}
+	case map[string]any:
+		_, ok := h[name]
+		return ok
+	}
+	return false
+}
+
+// mounts reports whether something is mounted at target.
+func (s composeService) mounts(target string) bool {
+	for _, v := range s.Volumes {
+		switch v := v.(type) {
+		case string:
+			if strings.HasSuffix(v, ":"+target) || strings.Contains(v, ":"+target+":") {
+				return true
+			}
+		case map[string]any:
+			if v["target"] == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadRenderedCompose returns an empty file when there is none yet.
+func loadRenderedCompose() renderedCompose {
+	var rc renderedCompose
+	if b, err := os.ReadFile("docker-compose.yml"); err == nil {
+		_ = yaml.Unmarshal(b, &rc)
+	}
+	return rc
+}
+
+func composeHasService(name string) bool {
+	_, ok := loadRenderedCompose().Services[name]
+	return ok
+}
@@ -30,15 +30,16 @@ var tlsInitCmd = &cobra.Command{
 			return fmt.Errorf("set a valid domain in .wpdev.yml (e.g., myshop.test)")
 		}
 
-		// Skip if already present (idempotent)
-		if certsPresent(cfg.Domain) {
-			fmt.Println("TLS certs already exist; skipping generation.")
-			return nil
-		}
-
-		// Do the work
-		if err := generateCertsForDomain(cfg.Domain); err != nil {
-			return err
+		// Skip domains whose certs are already present (idempotent);
+		// mapped multisite domains get their own
+		for _, d := range append([]string{cfg.Domain}, cfg.WordPress.Domains...) {
+			if certsPresent(d) {
+				fmt.Println("TLS certs for", d, "already exist; skipping generation.")
+				continue
+			}
+			if err := generateCertsForDomain(d); err != nil {
+				return err
+			}
 		}
 		fmt.Println("TLS init complete. Now run: wpdev start")
 		return nil
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// installWordPress runs on start when a wordpress: section is configured.
+// Every step checks first, so it only does work on the first start. Existing
+// sites only get wp-config-wpdev.php refreshed (and, with wordpress.multisite,
+// converted to a network once).
+func installWordPress(cfg *Config) error {
+	wp := cfg.WordPress
+	if wp.Version == "" {
+		if err := syncWPConfig(cfg); err != nil { return err }
+		return ensureNetwork(cfg)
+	}
+	docroot := cfg.Web.Docroot
+	if docroot == "" {
+		docroot = "."
+	}
+
+	if _, err := os.Stat(filepath.Join(docroot, "wp-includes", "version.php")); os.IsNotExist(err) {
+		archive, err := wordpressArchive(wp.Version, wpLocale(cfg))
+		if err != nil { return err }
+		fmt.Println("Extracting WordPress into", docroot)
+		if err := extractWordPress(archive, docroot); err != nil { return err }
+	}
+
+	wpConfig := filepath.Join(docroot, "wp-config.php")
+	if _, err := os.Stat(wpConfig); os.IsNotExist(err) {
+		if err := writeWPConfig(wpConfig); err != nil { return err }
+		fmt.Println("Wrote", wpConfig)
+	}
+	if err := syncWPConfig(cfg); err != nil { return err }
+
+	if err := waitForDB(cfg); err != nil { return err }
+	if wpExec(cfg, false, "core", "is-installed").Run() == nil {
+		return ensureNetwork(cfg)
+	}
+
+	// Multisite installs as a single site first; ensureNetwork converts it.
+	fmt.Println("Installing WordPress...")
+	c := wpExec(cfg, false, "core", "install",
+		"--url="+siteURL(cfg),
+		"--title="+wpTitle(cfg),
+		"--admin_user="+orDefault(wp.AdminUser, "admin"),
+		"--admin_password="+orDefault(wp.AdminPassword, "admin"),
+		"--admin_email="+orDefault(wp.AdminEmail, "admin@"+cfg.Domain),
+		"--skip-email",
+	)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	if err := c.Run(); err != nil { return err }
+	fmt.Printf("WordPress installed: %s/wp-admin (user %s)\n", siteURL(cfg), orDefault(wp.AdminUser, "admin"))
+	return ensureNetwork(cfg)
+}
+
+func wpTitle(cfg *Config) string {
+	return orDefault(cfg.WordPress.Title, cfg.Name)
+}
+
+func wpLocale(cfg *Config) string {
+	return orDefault(cfg.WordPress.Locale, "en_US")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// wordpressCacheDir keeps downloaded core archives per user, shared by all
+// projects, so installs work offline after the first download.
+func wordpressCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "wpdev", "wordpress")
+}
+
+// wordpressArchive returns a cached core tarball, downloading it if needed.
+// "latest" is resolved online and falls back to the newest cached release.
+func wordpressArchive(version, locale string) (string, error) {
+	dir := wordpressCacheDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil { return "", err }
+
+	if version == "latest" {
+		v, err := latestWordPress(locale)
+		if err != nil {
+			cached := cachedWordPress(dir, locale)
+			if cached == "" {
+				return "", fmt.Errorf("resolve latest WordPress: %w (and nothing cached in %s)", err, dir)
+			}
+			if err := verifyCachedWordPress(cached); err != nil { return "", err }
+			fmt.Println("Offline: using cached", filepath.Base(cached))
+			return cached, nil
+		}
+		version = v
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("wordpress-%s-%s.tar.gz", version, locale))
+	if _, err := os.Stat(path); err == nil {
+		if err = verifyCachedWordPress(path); err == nil {
+			return path, nil
+		}
+		fmt.Println("Downloading again:", err)
+	}
+	url := fmt.Sprintf("https://wordpress.org/wordpress-%s.tar.gz", version)
+	if locale != "en_US" {
+		url = fmt.Sprintf("https://downloads.wordpress.org/release/%s/wordpress-%s.tar.gz", locale, version)
+	}
+	fmt.Println("Downloading", url)
+	if err := download(url, path); err != nil { return "", err }
+	return path, nil
+}
+
+func latestWordPress(locale string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("https://api.wordpress.org/core/version-check/1.7/?locale=" + locale)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+	var body struct {
+		Offers []struct {
+			Current string `json:"current"`
+		} `json:"offers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil { return "", err }
+	if len(body.Offers) == 0 {
+		return "", fmt.Errorf("no release offered")
+	}
+	return body.Offers[0].Current, nil
+}
+
+func cachedWordPress(dir, locale string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "wordpress-*-"+locale+".tar.gz"))
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, _ := os.Stat(matches[i])
+		b, _ := os.Stat(matches[j])
+		return a.ModTime().Before(b.ModTime())
+	})
+	return matches[len(matches)-1]
+}
+
+// downloadClient gives up on a server that does not answer or sends too
+// slowly instead of hanging `wpdev start`.
+var downloadClient = func() *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = 30 * time.Second
+	return &http.Client{Transport: tr, Timeout: 10 * time.Minute}
+}()
+
+// download fetches url to dst, checking it against the url+".sha1" that
+// wordpress.org publishes next to each archive. The checksum is kept in
+// dst+".sha1" so the cached copy can be checked again before reuse.
+func download(url, dst string) error {
+	want, err := fetchSHA1(url + ".sha1")
+	if err != nil { return err }
+	resp, err := downloadClient.Get(url)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	tmp := dst + ".part"
+	f, err := os.Create(tmp)
+	if err != nil { return err }
+	h := sha1.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil { err = cerr }
+	if got := hex.EncodeToString(h.Sum(nil)); err == nil && got != want {
+		err = fmt.Errorf("%s: checksum mismatch (sha1 %s, expected %s)", url, got, want)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.WriteFile(dst+".sha1", []byte(want+"\n"), 0o644); err != nil { return err }
+	return os.Rename(tmp, dst)
+}
+
+func fetchSHA1(url string) (string, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil { return "", err }
+	if f := strings.Fields(string(b)); len(f) > 0 && len(f[0]) == 40 {
+		return strings.ToLower(f[0]), nil
+	}
+	return "", fmt.Errorf("GET %s: not a sha1 checksum", url)
+}
+
+// verifyCachedWordPress checks a cached archive against the checksum saved
+// when it was downloaded and deletes it when that fails.
+func verifyCachedWordPress(path string) error {
+	err := func() error {
+		b, err := os.ReadFile(path + ".sha1")
+		if err != nil { return fmt.Errorf("no checksum recorded for %s", filepath.Base(path)) }
+		f, err := os.Open(path)
+		if err != nil { return err }
+		defer f.Close()
+		h := sha1.New()
+		if _, err := io.Copy(h, f); err != nil { return err }
+		if hex.EncodeToString(h.Sum(nil)) != strings.TrimSpace(string(b)) {
+			return fmt.Errorf("cached %s is corrupt", filepath.Base(path))
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".sha1")
+	}
+	return err
+}
+
+// extractWordPress unpacks the release's wordpress/ directory into docroot.
+func extractWordPress(archive, docroot string) error {
+	f, err := os.Open(archive)
+	if err != nil { return err }
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil { return err }
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil { return err }
+		name, ok := strings.CutPrefix(hdr.Name, "wordpress/")
+		if !ok || name == "" || strings.Contains(name, "..") {
+			continue
+		}
+		dst := filepath.Join(docroot, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0o755); err != nil { return err }
+		case tar.TypeReg:
+			_ = os.MkdirAll(filepath.Dir(dst), 0o755)
+			out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil { return err }
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil { return err }
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/spf13/cobra"
+)
+
+var wpCmd = &cobra.Command{
+	Use:                "wp [args...]",
+	Short:              "Run WP-CLI in the php container (e.g. wpdev wp plugin list)",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(".wpdev.yml")
+		if err != nil { return err }
+		c := wpExec(cfg, stdinIsTTY(), args...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return exitWith(c.Run())
+	},
+}
+
+var composerCmd = &cobra.Command{
+	Use:                "composer [args...]",
+	Short:              "Run Composer in the php container (e.g. wpdev composer install)",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := phpExec(stdinIsTTY(), append([]string{"composer"}, args...)...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return exitWith(c.Run())
+	},
+}
+
+var execCmd = &cobra.Command{
+	Use:                "exec [--root] [command...]",
+	Short:              "Run a command in the php container as your user (default: bash)",
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := "www-data"
+		if len(args) > 0 && args[0] == "--root" {
+			user, args = "root", args[1:]
+		}
+		if len(args) == 0 {
+			args = []string{"bash"}
+		}
+		c := phpExecAs(user, stdinIsTTY(), args...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return exitWith(c.Run())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(wpCmd, composerCmd, execCmd)
+}
+
+// wpPath is the WordPress root inside the php container.
+func wpPath(cfg *Config) string {
+	return path.Join("/var/www/html", cfg.Web.Docroot)
+}
+
+// phpExec builds `docker compose exec` into the php service as www-data,
+// which the image maps to the host user, so files WP-CLI or composer create
+// stay editable on the host.
+func phpExec(tty bool, args ...string) *exec.Cmd {
+	return phpExecAs("www-data", tty, args...)
+}
+
+func phpExecAs(user string, tty bool, args ...string) *exec.Cmd {
+	a := []string{"compose", "exec"}
+	if !tty {
+		a = append(a, "-T")
+	}
+	a = append(a, "--user", user)
+	if user != "root" {
+		a = append(a, "-e", "HOME=/tmp")
+	}
+	a = append(a, "php")
+	return exec.Command("docker", append(a, args...)...)
+}
+
+func wpExec(cfg *Config, tty bool, args ...string) *exec.Cmd {
+	return phpExec(tty, append([]string{"wp", "--path=" + wpPath(cfg)}, args...)...)
+}
+
+func stdinIsTTY() bool {
+	st, err := os.Stdin.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// exitWith mirrors a child's exit status so scripts can rely on it.
+func exitWith(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() > 0 {
+		os.Exit(ee.ExitCode())
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	wpConfigInclude = "wp-config-wpdev.php"
+	wpConfigRequire = "require_once __DIR__ . '/" + wpConfigInclude + "'; // managed by wpdev"
+)
+
+func wpConfigPath(cfg *Config) string {
+	return filepath.Join(orDefault(cfg.Web.Docroot, "."), "wp-config.php")
+}
+
+var tablePrefixLine = regexp.MustCompile(`(?m)^\s*\$table_prefix\s*=\s*['"]([A-Za-z0-9_]+)['"]`)
+
+// tablePrefix reads $table_prefix from wp-config.php. WordPress only allows
+// letters, digits and underscores there, so the result is safe in SQL.
+func tablePrefix(cfg *Config) string {
+	b, err := os.ReadFile(wpConfigPath(cfg))
+	if err != nil { return "wp_" }
+	if m := tablePrefixLine.FindSubmatch(b); m != nil {
+		return string(m[1])
+	}
+	return "wp_"
+}
+
+// syncWPConfig renders wp-config-wpdev.php from .wpdev.yml and makes sure
+// wp-config.php requires it. Nothing else in wp-config.php is touched.
+func syncWPConfig(cfg *Config) error {
+	docroot := orDefault(cfg.Web.Docroot, ".")
+	wpConfig := wpConfigPath(cfg)
+	if _, err := os.Stat(wpConfig); os.IsNotExist(err) {
+		return nil
+	}
+
+	tpl, err := template.New(wpConfigInclude).Parse(wpConfigIncludeTemplate)
+	if err != nil { return err }
+	var out bytes.Buffer
+	network, err := networkInstalled(cfg)
+	if err != nil { return err }
+	// Only hand WP-Cron to the cron service when the compose file has one
+	cron := cfg.Services.Cron && composeHasService("cron")
+	if cfg.Services.Cron && !cron {
+		fmt.Println("Note: services.cron is on but docker-compose.yml has no cron service; WP-Cron keeps running on page loads.",
+			"Refresh the templates with `wpdev init --refresh-templates`.")
+	}
+	data := map[string]any{"Cfg": cfg, "URL": siteURL(cfg), "Network": network, "Cron": cron}
+	if err := tpl.Execute(&out, data); err != nil { return err }
+	if err := os.WriteFile(filepath.Join(docroot, wpConfigInclude), out.Bytes(), 0o644); err != nil { return err }
+	if network && cfg.Web.Server == "apache" && cfg.WordPress.Multisite == "subdirectory" {
+		if err := syncHtaccess(filepath.Join(docroot, ".htaccess")); err != nil { return err }
+	}
+
+	if err := injectWPConfigRequire(wpConfig); err != nil { return err }
+	return warnDebugDefines(wpConfig)
+}
+
+var debugDefine = regexp.MustCompile(`^\s*define\(\s*['"](WP_DEBUG|WP_DEBUG_LOG|WP_DEBUG_DISPLAY|SCRIPT_DEBUG|SAVEQUERIES)['"]`)
+
+// warnDebugDefines points out debug constants defined above the require,
+// like the stock `define( 'WP_DEBUG', false );`: they win over
+// `wpdev debug on|off`, and only the user may remove them.
+func warnDebugDefines(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil { return err }
+	for _, l := range strings.Split(string(b), "\n") {
+		if strings.Contains(l, wpConfigInclude) {
+			break
+		}
+		if m := debugDefine.FindStringSubmatch(l); m != nil {
+			fmt.Printf("Warning: %s defines %s before %s, so `wpdev debug` cannot change it; remove that line to let wpdev manage it.\n",
+				path, m[1], wpConfigInclude)
+		}
+	}
+	return nil
+}
+
+// injectWPConfigRequire adds the require right before wp-settings.php is
+// loaded, so constants the user defines above it still take precedence.
+func injectWPConfigRequire(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil { return err }
+	src := string(b)
+	if strings.Contains(src, wpConfigInclude) {
+		return nil
+	}
+
+	lines := strings.SplitAfter(src, "\n")
+	at := -1
+	for i, l := range lines {
+		if strings.Contains(l, "That's all, stop editing") || strings.Contains(l, "wp-settings.php") {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return fmt.Errorf("%s: cannot find where wp-settings.php is loaded; add this line before it:\n  %s", path, wpConfigRequire)
+	}
+	out := strings.Join(lines[:at], "") + wpConfigRequire + "\n\n" + strings.Join(lines[at:], "")
+	if err := os.WriteFile(path, []byte(out), 0o644); err != nil { return err }
+	fmt.Println("Added", wpConfigInclude, "to", path)
+	return nil
+}
+
+// writeWPConfig creates a minimal wp-config.php: salts and table prefix stay
+// here, everything derived from .wpdev.yml lives in wp-config-wpdev.php.
+func writeWPConfig(path string) error {
+	tpl, err := template.New("wp-config").Parse(wpConfigTemplate)
+	if err != nil { return err }
+	salts := map[string]string{}
+	for _, k := range []string{"AUTH_KEY", "SECURE_AUTH_KEY", "LOGGED_IN_KEY", "NONCE_KEY", "AUTH_SALT", "SECURE_AUTH_SALT", "LOGGED_IN_SALT", "NONCE_SALT"} {
+		s, err := randomSalt(64)
+		if err != nil { return err }
+		salts[k] = s
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil { return err }
+	defer f.Close()
+	return tpl.Execute(f, map[string]any{"Salts": salts, "Require": wpConfigRequire})
+}
+
+// randomSalt avoids ' and \ so values can sit in single-quoted PHP strings.
+func randomSalt(n int) (string, error) {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()-_[]{}<>~`+=,.;:/?|"
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil { return "", err }
+		b[i] = chars[k.Int64()]
+	}
+	return string(b), nil
+}
+
+const wpConfigTemplate = `<?php
+// Generated by wpdev on first start. Database, URLs, debug and cache
+// settings come from wp-config-wpdev.php, rendered from .wpdev.yml.
+
+{{ range $k, $v := .Salts }}define( '{{ $k }}', '{{ $v }}' );
+{{ end }}
+$table_prefix = 'wp_';
+
+{{ .Require }}
+
+/* That's all, stop editing! Happy publishing. */
+
+if ( ! defined( 'ABSPATH' ) ) {
+	define( 'ABSPATH', __DIR__ . '/' );
+}
+
+require_once ABSPATH . 'wp-settings.php';
+`
+
+const wpConfigIncludeTemplate = `<?php
+// Generated by wpdev from .wpdev.yml on every start; edits are overwritten.
+// Constants defined in wp-config.php before the require take precedence.
+{{ $c := .Cfg }}
+// Database (db service in docker-compose.yml)
+defined( 'DB_NAME' ) || define( 'DB_NAME', 'wordpress' );
+defined( 'DB_USER' ) || define( 'DB_USER', 'wp' );
+defined( 'DB_PASSWORD' ) || define( 'DB_PASSWORD', 'secret' );
+defined( 'DB_HOST' ) || define( 'DB_HOST', 'db' );
+defined( 'DB_CHARSET' ) || define( 'DB_CHARSET', 'utf8mb4' );
+defined( 'DB_COLLATE' ) || define( 'DB_COLLATE', '' );
+{{ if .Network }}
+// Multisite network (wordpress.multisite); each site keeps its URL in the database
+defined( 'WP_ALLOW_MULTISITE' ) || define( 'WP_ALLOW_MULTISITE', true );
+defined( 'MULTISITE' ) || define( 'MULTISITE', true );
+defined( 'SUBDOMAIN_INSTALL' ) || define( 'SUBDOMAIN_INSTALL', {{ if eq $c.WordPress.Multisite "subdomain" }}true{{ else }}false{{ end }} );
+defined( 'DOMAIN_CURRENT_SITE' ) || define( 'DOMAIN_CURRENT_SITE', '{{ $c.Domain }}' );
+defined( 'PATH_CURRENT_SITE' ) || define( 'PATH_CURRENT_SITE', '/' );
+defined( 'SITE_ID_CURRENT_SITE' ) || define( 'SITE_ID_CURRENT_SITE', 1 );
+defined( 'BLOG_ID_CURRENT_SITE' ) || define( 'BLOG_ID_CURRENT_SITE', 1 );
+{{- else }}
+// URLs
+defined( 'WP_HOME' ) || define( 'WP_HOME', '{{ .URL }}' );
+defined( 'WP_SITEURL' ) || define( 'WP_SITEURL', '{{ .URL }}' );
+{{- end }}
+defined( 'WP_ENVIRONMENT_TYPE' ) || define( 'WP_ENVIRONMENT_TYPE', 'local' );
+
+// Caddy terminates TLS and forwards the original scheme.
+if ( isset( $_SERVER['HTTP_X_FORWARDED_PROTO'] ) && $_SERVER['HTTP_X_FORWARDED_PROTO'] === 'https' ) {
+	$_SERVER['HTTPS'] = 'on';
+	$_SERVER['SERVER_PORT'] = 443;
+}
+
+// Debugging (wordpress.debug, toggled by wpdev debug on|off)
+{{- $d := "false" }}{{ if $c.WordPress.Debug }}{{ $d = "true" }}{{ end }}
+defined( 'WP_DEBUG' ) || define( 'WP_DEBUG', {{ $d }} );
+defined( 'WP_DEBUG_LOG' ) || define( 'WP_DEBUG_LOG', {{ $d }} );
+defined( 'WP_DEBUG_DISPLAY' ) || define( 'WP_DEBUG_DISPLAY', {{ $d }} );
+defined( 'SCRIPT_DEBUG' ) || define( 'SCRIPT_DEBUG', {{ $d }} );
+defined( 'SAVEQUERIES' ) || define( 'SAVEQUERIES', {{ $d }} );
+// Overrides display_errors = Off from the image's error-logging.ini
+@ini_set( 'display_errors', '{{ if $c.WordPress.Debug }}1{{ else }}0{{ end }}' );
+{{- if .Cron }}
+
+// WP-Cron runs from the cron service (services.cron), not on page loads
+defined( 'DISABLE_WP_CRON' ) || define( 'DISABLE_WP_CRON', true );
+{{- end }}
+{{- if $c.Services.Redis }}
+
+// Object cache (redis service), e.g. for the Redis Object Cache plugin
+defined( 'WP_REDIS_HOST' ) || define( 'WP_REDIS_HOST', 'redis' );
+defined( 'WP_REDIS_PORT' ) || define( 'WP_REDIS_PORT', 6379 );
+defined( 'WP_CACHE_KEY_SALT' ) || define( 'WP_CACHE_KEY_SALT', '{{ $c.Domain }}:' );
+{{- end }}
+`
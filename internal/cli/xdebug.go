@@ -2,26 +2,140 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
+// xdebugOutputDir receives profiles and traces; it is .wpdev/xdebug on the host.
+const xdebugOutputDir = "/var/www/html/.wpdev/xdebug"
+
+var xdebugModes = []string{"debug", "profile", "trace", "coverage", "develop", "off"}
+
+var xdebugTrigger, xdebugAlways bool
+
 var xdebugCmd = &cobra.Command{
-	Use:   "xdebug [on|off]",
-	Short: "Toggle Xdebug",
-	Args:  cobra.ExactArgs(1),
+	Use:   "xdebug [debug|profile|trace|coverage|develop|off|status]",
+	Short: "Switch the Xdebug mode without rebuilding",
+	Long: `Switch the Xdebug mode without rebuilding.
+
+debug starts a session on every request; profile and trace only when the
+XDEBUG_TRIGGER cookie, GET or POST parameter is present. Override with
+--trigger or --always. "on" is an alias for debug.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		mode := args[0]
 		cfg, err := loadConfig(".wpdev.yml")
 		if err != nil { return err }
+		mode := "status"
+		if len(args) == 1 {
+			mode = args[0]
+		}
 		if mode == "on" {
-			cfg.Xdebug.Enabled = true
-		} else if mode == "off" {
-			cfg.Xdebug.Enabled = false
-		} else {
-			return fmt.Errorf("unknown mode %q, use on|off", mode)
+			mode = "debug"
+		}
+		if mode == "status" {
+			return xdebugStatus(cfg)
+		}
+		if !validXdebugMode(mode) {
+			return fmt.Errorf("unknown mode %q, use %s or status", mode, strings.Join(xdebugModes, "|"))
+		}
+
+		start := ""
+		switch {
+		case xdebugTrigger:
+			start = "trigger"
+		case xdebugAlways:
+			start = "yes"
+		case mode == "debug":
+			start = "yes"
+		case mode == "profile" || mode == "trace":
+			start = "trigger"
+		}
+		cfg.Xdebug.Mode, cfg.Xdebug.StartWithRequest, cfg.Xdebug.Enabled = mode, start, false
+		if err := checkPHPIniMount(cfg); err != nil { return err }
+		if strings.Contains(mode, "debug") {
+			if err := checkHostGateway(cfg); err != nil { return err }
 		}
 		if err := saveConfig(".wpdev.yml", cfg); err != nil { return err }
-		fmt.Println("Xdebug set to", mode, "— rebuilding PHP container...")
-		return rebuildCmd.RunE(cmd, nil)
+
+		changed, err := syncPHPIni(cfg)
+		if err != nil { return err }
+		if changed {
+			reloadPHP(cfg)
+		}
+		fmt.Print("Xdebug mode: ", mode)
+		if start != "" {
+			fmt.Print(" (start_with_request=", start, ")")
+		}
+		fmt.Println()
+		if mode == "debug" {
+			maps, err := xdebugPathMappings(cfg)
+			if err != nil { return err }
+			fmt.Printf("Path mappings for your IDE (server name %s, port %d):\n", ideServerName(cfg), cfg.XdebugClientPort())
+			for _, m := range maps {
+				fmt.Printf("  %s => %s\n", m.Container, m.Host)
+			}
+			fmt.Println("Generate the IDE config with: wpdev xdebug ide vscode|phpstorm")
+		}
+		return nil
 	},
 }
+
+func init() {
+	xdebugCmd.Flags().BoolVar(&xdebugTrigger, "trigger", false, "only start with the XDEBUG_TRIGGER cookie/parameter")
+	xdebugCmd.Flags().BoolVar(&xdebugAlways, "always", false, "start on every request")
+	xdebugCmd.MarkFlagsMutuallyExclusive("trigger", "always")
+}
+
+// validXdebugMode accepts one mode or a comma list like debug,develop.
+func validXdebugMode(mode string) bool {
+	for _, m := range strings.Split(mode, ",") {
+		ok := false
+		for _, v := range xdebugModes {
+			ok = ok || m == v
+		}
+		if !ok || (m == "off" && mode != "off") {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHostGateway catches compose files rendered before host.docker.internal
+// was mapped on Linux: Xdebug would never reach the IDE.
+func checkHostGateway(cfg *Config) error {
+	php, ok := loadRenderedCompose().Services["php"]
+	if !cfg.HostGateway() || !ok || php.mapsHost("host.docker.internal") {
+		return nil
+	}
+	return fmt.Errorf("docker-compose.yml does not map host.docker.internal, which Xdebug connects to on Linux; refresh the templates with `wpdev init --refresh-templates`")
+}
+
+// reloadPHP makes the running PHP re-read its ini files: a graceful FPM or
+// Apache reload, which takes well under a second. A stopped stack picks the
+// settings up on its next start.
+func reloadPHP(cfg *Config) {
+	signal := "-USR2" // php-fpm graceful reload
+	if cfg.Web.Server == "apache" {
+		signal = "-USR1" // apache graceful restart
+	}
+	if err := phpExecAs("root", false, "kill", signal, "1").Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "PHP is not running; the new settings apply on the next `wpdev start`.")
+	}
+}
+
+func xdebugStatus(cfg *Config) error {
+	fmt.Println("configured:", cfg.XdebugMode())
+	if cfg.Xdebug.StartWithRequest != "" {
+		fmt.Println("start_with_request:", cfg.Xdebug.StartWithRequest)
+	}
+	out, err := phpExec(false, "php", "-r",
+		`echo extension_loaded('xdebug') ? phpversion('xdebug') . ' loaded, mode ' . ini_get('xdebug.mode') : 'not loaded', "\n";`).Output()
+	if err != nil {
+		fmt.Println("(start the stack to see what PHP runs with)")
+		return nil
+	}
+	fmt.Print("running: ", string(out))
+	return nil
+}
@@ -0,0 +1,29 @@
+package cli
+
+import "testing"
+
+func TestValidXdebugMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		ok   bool
+	}{
+		{"debug", true},
+		{"profile", true},
+		{"off", true},
+		{"debug,develop", true},
+		{"debug,profile,trace", true},
+		{"", false},
+		{"on", false}, // the command maps "on" to debug before validating
+		{"debug,", false},
+		{"debug, develop", false},
+		{"Debug", false},
+		{"debug,off", false},
+		{"off,off", false},
+		{"gcstats", false},
+	}
+	for _, tt := range tests {
+		if got := validXdebugMode(tt.mode); got != tt.ok {
+			t.Errorf("validXdebugMode(%q) = %v, want %v", tt.mode, got, tt.ok)
+		}
+	}
+}